@@ -55,7 +55,7 @@ func (h *AuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userInfo, _, err := h.Google.GetUserInfo(ctx, code)
+	userInfo, oauthToken, err := h.Google.GetUserInfo(ctx, code)
 	if err != nil {
 		http.Error(w, "failed to get userinfo: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -73,6 +73,15 @@ func (h *AuthHandler) GoogleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Google only hands out a refresh token when the user grants access, not on every
+	// sign-in, so keep the one we have until a new one arrives.
+	if oauthToken.RefreshToken != "" {
+		if err := h.UserRepo.SetGoogleRefreshToken(ctx, user.UserID, oauthToken.RefreshToken); err != nil {
+			http.Error(w, "failed to save user: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	// Generate JWT
 	token, err := GenerateJWT(h.JWTSecret, user.UserID, user.Email, 7*24*time.Hour)
 	if err != nil {
@@ -3,19 +3,38 @@ package api
 import (
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
+
+	"github.com/juhun32/patriot25-gochi/go/google"
+	"github.com/juhun32/patriot25-gochi/go/models"
 )
 
 type Config struct {
 	GoogleClientID     string
 	GoogleClientSecret string
 	GoogleRedirectURL  string
+	CalendarAPIURL     string
 
 	AWSAccessKeyID     string
 	AWSSecretAccessKey string
 	AWSRegion          string
 	UsersTable         string
+	TodosTable         string
+	TodoTagsTable      string
+	TodoHistoryTable   string
+	RemindersTable     string
+	ProjectsTable      string
+	TimeSessionsTable  string
+	SharesTable        string
+	PetStatesTable     string
+	TrashRetention     time.Duration
+	ReminderInterval   time.Duration
+	CalendarInterval   time.Duration
+	PetScoring         models.Scoring
+	PetGrowth          models.Growth
 
 	JWTSecret string
 }
@@ -31,19 +50,89 @@ func Load() *Config {
 		GoogleClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
 		GoogleClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
 		GoogleRedirectURL:  os.Getenv("GOOGLE_REDIRECT_URL"),
+		CalendarAPIURL:     os.Getenv("GOOGLE_CALENDAR_API_URL"),
 
 		AWSAccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
 		AWSSecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
 		AWSRegion:          os.Getenv("AWS_REGION"),
 		UsersTable:         os.Getenv("USERS_TABLE"),
+		TodosTable:         os.Getenv("TODOS_TABLE"),
+		TodoTagsTable:      os.Getenv("TODO_TAGS_TABLE"),
+		TodoHistoryTable:   os.Getenv("TODO_HISTORY_TABLE"),
+		RemindersTable:     os.Getenv("REMINDERS_TABLE"),
+		ProjectsTable:      os.Getenv("PROJECTS_TABLE"),
+		TimeSessionsTable:  os.Getenv("TIME_SESSIONS_TABLE"),
+		SharesTable:        os.Getenv("SHARES_TABLE"),
+		PetStatesTable:     os.Getenv("PET_STATES_TABLE"),
 
 		JWTSecret: os.Getenv("JWT_SECRET"),
 	}
 
+	cfg.TrashRetention = 30 * 24 * time.Hour
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days <= 0 {
+			log.Fatal("TRASH_RETENTION_DAYS must be a positive number of days")
+		}
+		cfg.TrashRetention = time.Duration(days) * 24 * time.Hour
+	}
+
+	cfg.ReminderInterval = time.Minute
+	if v := os.Getenv("REMINDER_INTERVAL_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs <= 0 {
+			log.Fatal("REMINDER_INTERVAL_SECONDS must be a positive number of seconds")
+		}
+		cfg.ReminderInterval = time.Duration(secs) * time.Second
+	}
+
+	if cfg.CalendarAPIURL == "" {
+		cfg.CalendarAPIURL = google.DefaultCalendarURL
+	}
+	cfg.CalendarInterval = 5 * time.Minute
+	if v := os.Getenv("CALENDAR_SYNC_INTERVAL_SECONDS"); v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs <= 0 {
+			log.Fatal("CALENDAR_SYNC_INTERVAL_SECONDS must be a positive number of seconds")
+		}
+		cfg.CalendarInterval = time.Duration(secs) * time.Second
+	}
+
+	cfg.PetScoring = models.DefaultScoring
+	if v := os.Getenv("PET_SCORE_HALF_LIFE_HOURS"); v != "" {
+		hours, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			log.Fatal("PET_SCORE_HALF_LIFE_HOURS must be a number of hours")
+		}
+		cfg.PetScoring.HalfLife = time.Duration(hours * float64(time.Hour))
+	}
+	if v := os.Getenv("PET_GOLDEN_SCORE"); v != "" {
+		if cfg.PetScoring.GoldenAt, err = strconv.ParseFloat(v, 64); err != nil {
+			log.Fatal("PET_GOLDEN_SCORE must be a number")
+		}
+	}
+	if v := os.Getenv("PET_GRUMPY_SCORE"); v != "" {
+		if cfg.PetScoring.GrumpyAt, err = strconv.ParseFloat(v, 64); err != nil {
+			log.Fatal("PET_GRUMPY_SCORE must be a number")
+		}
+	}
+	if err := cfg.PetScoring.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	cfg.PetGrowth = models.DefaultGrowth
+	if v := os.Getenv("PET_GROWTH_FILE"); v != "" {
+		if cfg.PetGrowth, err = models.LoadGrowth(v); err != nil {
+			log.Fatal("PET_GROWTH_FILE: ", err)
+		}
+	}
+
 	if cfg.GoogleClientID == "" || cfg.GoogleClientSecret == "" || cfg.GoogleRedirectURL == "" {
 		log.Fatal("Google OAuth env vars missing")
 	}
-	if cfg.AWSRegion == "" || cfg.UsersTable == "" {
+	if cfg.AWSRegion == "" || cfg.UsersTable == "" || cfg.TodosTable == "" || cfg.TodoTagsTable == "" ||
+		cfg.TodoHistoryTable == "" || cfg.RemindersTable == "" || cfg.ProjectsTable == "" ||
+		cfg.TimeSessionsTable == "" || cfg.SharesTable == "" || cfg.PetStatesTable == "" {
 		log.Fatal("AWS env vars missing")
 	}
 
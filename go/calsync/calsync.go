@@ -0,0 +1,332 @@
+// Package calsync keeps todos with due times in step with events on each user's Google
+// Calendar. Every pass first pulls calendar changes since the user's sync token, so a
+// todo follows its event when the event is moved and loses its due time when the event
+// is deleted, and then pushes todos that changed in the app. When both sides changed
+// since the last pass, the app wins.
+package calsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/juhun32/patriot25-gochi/go/google"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+// EventLength is how long the event standing in for a todo lasts; it starts at the
+// todo's due time, as in the iCalendar feed.
+const EventLength = 30 * time.Minute
+
+// todoIDProperty is the private extended property that ties an event to its todo.
+const todoIDProperty = "gochiTodoId"
+
+// All-day events read back as due at the end of their day, as in quick-add.
+const (
+	allDayHour   = 23
+	allDayMinute = 59
+)
+
+// TodoStore is the part of the todo repo that sync needs.
+type TodoStore interface {
+	ListTodos(ctx context.Context, userID string) ([]models.Todo, error)
+	ListTrash(ctx context.Context, userID string) ([]models.Todo, error)
+	UpdateTodo(ctx context.Context, userID, todoID string, upd repo.TodoUpdate) (*models.Todo, error)
+	SetCalendarEvent(ctx context.Context, userID, todoID, eventID string, version int64) error
+}
+
+// UserStore finds the users to sync and keeps their sync state.
+type UserStore interface {
+	ListCalendarUsers(ctx context.Context) ([]models.User, error)
+	SetCalendarSyncToken(ctx context.Context, userID, token string) error
+	SetGoogleRefreshToken(ctx context.Context, userID, token string) error
+}
+
+// Calendar is a user's primary calendar; *google.Calendar implements it.
+type Calendar interface {
+	InsertEvent(ctx context.Context, e *google.Event) (*google.Event, error)
+	PatchEvent(ctx context.Context, eventID string, e *google.Event) (*google.Event, error)
+	DeleteEvent(ctx context.Context, eventID string) error
+	ListEvents(ctx context.Context, syncToken, pageToken string) (*google.EventPage, error)
+}
+
+// CalendarFunc opens the calendar of a user who has sync set up.
+type CalendarFunc func(ctx context.Context, user *models.User) Calendar
+
+type Service struct {
+	todos    TodoStore
+	users    UserStore
+	calendar CalendarFunc
+}
+
+func NewService(todos TodoStore, users UserStore, calendar CalendarFunc) *Service {
+	return &Service{
+		todos:    todos,
+		users:    users,
+		calendar: calendar,
+	}
+}
+
+// Run calls Tick every interval until ctx is done, logging failed passes.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Tick(ctx); err != nil {
+			log.Println("calendar sync pass failed:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick syncs every user with calendar sync set up. A failure for one user does not
+// hold up the rest. Users whose Google grant was revoked are dropped from sync.
+func (s *Service) Tick(ctx context.Context) error {
+	users, err := s.users.ListCalendarUsers(ctx)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for i := range users {
+		err := s.SyncUser(ctx, &users[i])
+		var grant *oauth2.RetrieveError
+		if errors.As(err, &grant) && grant.ErrorCode == "invalid_grant" {
+			err = s.users.SetGoogleRefreshToken(ctx, users[i].UserID, "")
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("user %s: %w", users[i].UserID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SyncUser runs one pull and push for user.
+func (s *Service) SyncUser(ctx context.Context, user *models.User) error {
+	live, err := s.todos.ListTodos(ctx, user.UserID)
+	if err != nil {
+		return err
+	}
+	trashed, err := s.todos.ListTrash(ctx, user.UserID)
+	if err != nil {
+		return err
+	}
+	todos := append(live, trashed...)
+
+	cal := s.calendar(ctx, user)
+	if err := s.pull(ctx, cal, user, todos); err != nil {
+		return err
+	}
+	return s.push(ctx, cal, todos)
+}
+
+// pull applies calendar changes since the user's sync token and stores the new token.
+// Without a token, or once Google has expired it, it lists the whole calendar and
+// treats linked events that are missing as deleted. todos is updated in place.
+func (s *Service) pull(ctx context.Context, cal Calendar, user *models.User, todos []models.Todo) error {
+	byEvent := map[string]*models.Todo{}
+	byID := map[string]*models.Todo{}
+	for i := range todos {
+		t := &todos[i]
+		byID[t.TodoID] = t
+		if t.CalendarEventID != "" {
+			byEvent[t.CalendarEventID] = t
+		}
+	}
+
+	syncToken := user.CalendarSyncToken
+	full := syncToken == ""
+	var events []google.Event
+	var loc *time.Location
+	for pageToken := ""; ; {
+		page, err := cal.ListEvents(ctx, syncToken, pageToken)
+		if errors.Is(err, google.ErrSyncTokenExpired) && syncToken != "" {
+			syncToken, pageToken, events, full = "", "", nil, true
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if loc == nil {
+			if loc, err = time.LoadLocation(page.TimeZone); err != nil {
+				loc = time.UTC
+			}
+		}
+		events = append(events, page.Items...)
+		if page.NextPageToken == "" {
+			syncToken = page.NextSyncToken
+			break
+		}
+		pageToken = page.NextPageToken
+	}
+
+	seen := map[string]bool{}
+	for _, e := range events {
+		seen[e.ID] = true
+		t := byEvent[e.ID]
+		if t == nil {
+			t = s.adopt(ctx, e, byID)
+		}
+		if t == nil || t.Trashed() || t.Version != t.CalendarVersion {
+			continue
+		}
+		if err := s.applyEvent(ctx, t, e, loc); err != nil {
+			return err
+		}
+	}
+	if full {
+		for id, t := range byEvent {
+			if !seen[id] && !t.Trashed() && t.Version == t.CalendarVersion {
+				if err := s.applyEvent(ctx, t, google.Event{ID: id, Status: google.EventCancelled}, loc); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if syncToken == "" || syncToken == user.CalendarSyncToken {
+		return nil
+	}
+	if err := s.users.SetCalendarSyncToken(ctx, user.UserID, syncToken); err != nil {
+		return err
+	}
+	user.CalendarSyncToken = syncToken
+	return nil
+}
+
+// adopt links a live event created for a todo whose event ID was never recorded, as
+// happens when a push fails between creating the event and saving its ID.
+func (s *Service) adopt(ctx context.Context, e google.Event, byID map[string]*models.Todo) *models.Todo {
+	if e.Status == google.EventCancelled || e.ExtendedProperties == nil {
+		return nil
+	}
+	t := byID[e.ExtendedProperties.Private[todoIDProperty]]
+	if t == nil || t.CalendarEventID != "" {
+		return nil
+	}
+	if err := s.todos.SetCalendarEvent(ctx, t.UserID, t.TodoID, e.ID, t.Version); err != nil {
+		return nil
+	}
+	t.CalendarEventID, t.CalendarVersion = e.ID, t.Version
+	return t
+}
+
+// applyEvent brings t in line with its event: a deleted event clears the due time and
+// the link, and a moved one moves the due time.
+func (s *Service) applyEvent(ctx context.Context, t *models.Todo, e google.Event, loc *time.Location) error {
+	var upd repo.TodoUpdate
+	eventID := t.CalendarEventID
+	if e.Status == google.EventCancelled {
+		eventID = ""
+		upd.ClearDueAt = t.DueAt != nil
+	} else {
+		// Events only hold whole seconds, so a due time with milliseconds has not
+		// moved if it still falls in the event's second.
+		due, ok := eventDue(e, loc)
+		if !ok || (t.DueAt != nil && time.UnixMilli(*t.DueAt).Truncate(time.Second).UnixMilli() == due) {
+			return nil
+		}
+		upd.DueAt = &due
+	}
+
+	next := t
+	if upd.DueAt != nil || upd.ClearDueAt {
+		updated, err := s.todos.UpdateTodo(ctx, t.UserID, t.TodoID, upd)
+		if errors.Is(err, repo.ErrTodoNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		next = updated
+	}
+	err := s.todos.SetCalendarEvent(ctx, t.UserID, t.TodoID, eventID, next.Version)
+	if err != nil && !errors.Is(err, repo.ErrPreconditionFailed) {
+		return err
+	}
+	*t = *next
+	if err == nil {
+		t.CalendarEventID, t.CalendarVersion = eventID, next.Version
+	}
+	return nil
+}
+
+// push creates, updates and deletes events for todos that changed since they were last
+// synced. Open todos with a due time get an event; done and trashed ones lose theirs.
+func (s *Service) push(ctx context.Context, cal Calendar, todos []models.Todo) error {
+	var errs []error
+	for i := range todos {
+		t := &todos[i]
+		want := t.DueAt != nil && !t.Done && !t.Trashed()
+		if t.CalendarEventID != "" && t.Version == t.CalendarVersion && want {
+			continue
+		}
+
+		eventID := t.CalendarEventID
+		var err error
+		switch {
+		case eventID == "" && !want:
+			continue
+		case eventID == "":
+			var e *google.Event
+			if e, err = cal.InsertEvent(ctx, todoEvent(t)); err == nil {
+				eventID = e.ID
+			}
+		case !want:
+			err = cal.DeleteEvent(ctx, eventID)
+			eventID = ""
+		default:
+			_, err = cal.PatchEvent(ctx, eventID, todoEvent(t))
+		}
+		// An event deleted from under us is recreated on the next pass.
+		if errors.Is(err, google.ErrEventNotFound) {
+			err, eventID = nil, ""
+		}
+		if err == nil {
+			err = s.todos.SetCalendarEvent(ctx, t.UserID, t.TodoID, eventID, t.Version)
+		}
+		if err != nil && !errors.Is(err, repo.ErrPreconditionFailed) {
+			errs = append(errs, fmt.Errorf("todo %s: %w", t.TodoID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// todoEvent is the event standing in for t.
+func todoEvent(t *models.Todo) *google.Event {
+	start := time.UnixMilli(*t.DueAt).UTC()
+	return &google.Event{
+		Summary:     t.Text,
+		Description: t.Notes,
+		Start:       &google.EventTime{DateTime: start.Format(time.RFC3339)},
+		End:         &google.EventTime{DateTime: start.Add(EventLength).Format(time.RFC3339)},
+		ExtendedProperties: &google.ExtendedProperties{
+			Private: map[string]string{todoIDProperty: t.TodoID},
+		},
+	}
+}
+
+// eventDue reads the due time back from an event's start. All-day events are due at
+// the end of their day in the calendar's time zone.
+func eventDue(e google.Event, loc *time.Location) (int64, bool) {
+	if e.Start == nil {
+		return 0, false
+	}
+	if e.Start.DateTime != "" {
+		t, err := time.Parse(time.RFC3339, e.Start.DateTime)
+		return t.UnixMilli(), err == nil
+	}
+	d, err := time.ParseInLocation(time.DateOnly, e.Start.Date, loc)
+	if err != nil {
+		return 0, false
+	}
+	return time.Date(d.Year(), d.Month(), d.Day(), allDayHour, allDayMinute, 0, 0, loc).UnixMilli(), true
+}
@@ -0,0 +1,398 @@
+package calsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/google"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+// fakeCalendar serves /calendars/primary/events like Google does, closely enough for
+// sync: a sync token is a generation and a position in the log of changed event IDs,
+// and tokens from an earlier generation are answered with 410 Gone.
+type fakeCalendar struct {
+	mu         sync.Mutex
+	events     map[string]*google.Event
+	changes    []string
+	generation int
+	fullLists  int
+}
+
+func newFakeCalendar(t *testing.T) (*fakeCalendar, *google.Calendar) {
+	f := &fakeCalendar{events: map[string]*google.Event{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/calendars/primary/events", f.serveEvents)
+	mux.HandleFunc("/calendars/primary/events/{id}", f.serveEvent)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return f, google.NewCalendar(srv.Client(), srv.URL)
+}
+
+func (f *fakeCalendar) serveEvents(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodGet:
+		page := google.EventPage{Items: []google.Event{}, TimeZone: "America/New_York"}
+		if token := r.URL.Query().Get("syncToken"); token != "" {
+			gen, pos, _ := strings.Cut(token, ":")
+			from, err := strconv.Atoi(pos)
+			if err != nil || gen != strconv.Itoa(f.generation) {
+				http.Error(w, "sync token expired", http.StatusGone)
+				return
+			}
+			seen := map[string]bool{}
+			for _, id := range f.changes[from:] {
+				if !seen[id] {
+					seen[id] = true
+					page.Items = append(page.Items, *f.events[id])
+				}
+			}
+		} else {
+			f.fullLists++
+			for _, e := range f.events {
+				if e.Status != google.EventCancelled {
+					page.Items = append(page.Items, *e)
+				}
+			}
+		}
+		page.NextSyncToken = fmt.Sprintf("%d:%d", f.generation, len(f.changes))
+		json.NewEncoder(w).Encode(page)
+	case http.MethodPost:
+		var e google.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		e.ID = fmt.Sprintf("evt%d", len(f.events)+1)
+		e.Status = google.EventConfirmed
+		f.events[e.ID] = &e
+		f.changes = append(f.changes, e.ID)
+		json.NewEncoder(w).Encode(e)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeCalendar) serveEvent(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	e := f.events[r.PathValue("id")]
+	if e == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if e.Status == google.EventCancelled {
+		http.Error(w, "deleted", http.StatusGone)
+		return
+	}
+	switch r.Method {
+	case http.MethodPatch:
+		var patch google.Event
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		e.Summary, e.Description, e.Start, e.End = patch.Summary, patch.Description, patch.Start, patch.End
+		f.changes = append(f.changes, e.ID)
+		json.NewEncoder(w).Encode(e)
+	case http.MethodDelete:
+		e.Status = google.EventCancelled
+		f.changes = append(f.changes, e.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// move changes an event's start, as the user would in Google Calendar.
+func (f *fakeCalendar) move(t *testing.T, id string, start time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := f.events[id]
+	if e == nil {
+		t.Fatalf("no event %s", id)
+	}
+	e.Start = &google.EventTime{DateTime: start.Format(time.RFC3339)}
+	e.End = &google.EventTime{DateTime: start.Add(EventLength).Format(time.RFC3339)}
+	f.changes = append(f.changes, id)
+}
+
+// remove deletes an event. Unlogged deletions are only noticed by a full listing.
+func (f *fakeCalendar) remove(id string, logged bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events[id].Status = google.EventCancelled
+	if logged {
+		f.changes = append(f.changes, id)
+	}
+}
+
+// expire makes Google forget every sync token handed out so far.
+func (f *fakeCalendar) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.generation++
+	f.fullLists = 0
+}
+
+// live returns the events that have not been deleted.
+func (f *fakeCalendar) live() []google.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []google.Event
+	for _, e := range f.events {
+		if e.Status != google.EventCancelled {
+			out = append(out, *e)
+		}
+	}
+	return out
+}
+
+// fakeStore keeps one user's todos and sync state in memory.
+type fakeStore struct {
+	user     models.User
+	todos    map[string]*models.Todo
+	failLink int // SetCalendarEvent calls left to fail
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		user:  models.User{UserID: "u1"},
+		todos: map[string]*models.Todo{},
+	}
+}
+
+func (s *fakeStore) ListTodos(ctx context.Context, userID string) ([]models.Todo, error) {
+	return s.list(false), nil
+}
+
+func (s *fakeStore) ListTrash(ctx context.Context, userID string) ([]models.Todo, error) {
+	return s.list(true), nil
+}
+
+func (s *fakeStore) list(trashed bool) []models.Todo {
+	var out []models.Todo
+	for _, t := range s.todos {
+		if t.Trashed() == trashed {
+			out = append(out, *t)
+		}
+	}
+	return out
+}
+
+func (s *fakeStore) UpdateTodo(ctx context.Context, userID, todoID string, upd repo.TodoUpdate) (*models.Todo, error) {
+	t := s.todos[todoID]
+	if t == nil || t.Trashed() {
+		return nil, repo.ErrTodoNotFound
+	}
+	if upd.ClearDueAt {
+		t.DueAt = nil
+	} else if upd.DueAt != nil {
+		t.DueAt = upd.DueAt
+	}
+	t.Version++
+	out := *t
+	return &out, nil
+}
+
+func (s *fakeStore) SetCalendarEvent(ctx context.Context, userID, todoID, eventID string, version int64) error {
+	if s.failLink > 0 {
+		s.failLink--
+		return errors.New("write failed")
+	}
+	t := s.todos[todoID]
+	if t == nil || t.Version != version {
+		return repo.ErrPreconditionFailed
+	}
+	t.CalendarEventID, t.CalendarVersion = eventID, version
+	return nil
+}
+
+func (s *fakeStore) ListCalendarUsers(ctx context.Context) ([]models.User, error) {
+	return []models.User{s.user}, nil
+}
+
+func (s *fakeStore) SetCalendarSyncToken(ctx context.Context, userID, token string) error {
+	s.user.CalendarSyncToken = token
+	return nil
+}
+
+func (s *fakeStore) SetGoogleRefreshToken(ctx context.Context, userID, token string) error {
+	return nil
+}
+
+// add stores a new todo due at due.
+func (s *fakeStore) add(id string, due time.Time) *models.Todo {
+	ms := due.UnixMilli()
+	t := &models.Todo{UserID: s.user.UserID, TodoID: id, Text: "todo " + id, DueAt: &ms, Version: 1}
+	s.todos[id] = t
+	return t
+}
+
+// edit moves a todo's due time in the app.
+func (s *fakeStore) edit(id string, due time.Time) {
+	ms := due.UnixMilli()
+	s.todos[id].DueAt = &ms
+	s.todos[id].Version++
+}
+
+func setup(t *testing.T) (*fakeStore, *fakeCalendar, func() error) {
+	store := newFakeStore()
+	cal, client := newFakeCalendar(t)
+	svc := NewService(store, store, func(ctx context.Context, user *models.User) Calendar {
+		return client
+	})
+	return store, cal, func() error {
+		return svc.SyncUser(context.Background(), &store.user)
+	}
+}
+
+func mustSync(t *testing.T, sync func() error) {
+	t.Helper()
+	if err := sync(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func eventStart(t *testing.T, e google.Event) time.Time {
+	t.Helper()
+	start, err := time.Parse(time.RFC3339, e.Start.DateTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return start
+}
+
+var due = time.Date(2026, 3, 6, 17, 0, 0, 0, time.UTC)
+
+func TestInsertThenPullMove(t *testing.T) {
+	store, cal, sync := setup(t)
+	// Milliseconds the event cannot hold must not read back as a move.
+	todo := store.add("t1", due.Add(250*time.Millisecond))
+	mustSync(t, sync)
+
+	events := cal.live()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if got := eventStart(t, events[0]); !got.Equal(due) {
+		t.Errorf("event starts at %v, want %v", got, due)
+	}
+	if todo.CalendarEventID != events[0].ID || todo.CalendarVersion != todo.Version {
+		t.Fatalf("todo not linked: %+v", todo)
+	}
+
+	// The next pass sees our own insert come back unchanged.
+	mustSync(t, sync)
+	if todo.Version != 1 || *todo.DueAt != due.Add(250*time.Millisecond).UnixMilli() {
+		t.Errorf("unchanged event edited the todo: version %d, due %d", todo.Version, *todo.DueAt)
+	}
+
+	moved := due.Add(2 * time.Hour)
+	cal.move(t, events[0].ID, moved)
+	mustSync(t, sync)
+	if todo.DueAt == nil || *todo.DueAt != moved.UnixMilli() {
+		t.Errorf("todo due %v, want %v", todo.DueAt, moved)
+	}
+	if todo.CalendarVersion != todo.Version {
+		t.Errorf("pulled move left the todo to be pushed again")
+	}
+}
+
+func TestPullDeleteClearsDue(t *testing.T) {
+	store, cal, sync := setup(t)
+	todo := store.add("t1", due)
+	mustSync(t, sync)
+
+	cal.remove(todo.CalendarEventID, true)
+	mustSync(t, sync)
+	if todo.DueAt != nil || todo.CalendarEventID != "" {
+		t.Errorf("todo kept due %v and event %q", todo.DueAt, todo.CalendarEventID)
+	}
+	if len(cal.live()) != 0 {
+		t.Errorf("deleted event was pushed back")
+	}
+}
+
+func TestExpiredSyncTokenResyncs(t *testing.T) {
+	store, cal, sync := setup(t)
+	kept := store.add("t1", due)
+	gone := store.add("t2", due)
+	mustSync(t, sync)
+	token := store.user.CalendarSyncToken
+
+	// The deletion is only visible to a full listing once the token has expired.
+	cal.remove(gone.CalendarEventID, false)
+	cal.expire()
+
+	mustSync(t, sync)
+	if cal.fullLists != 1 {
+		t.Errorf("got %d full listings, want 1", cal.fullLists)
+	}
+	if store.user.CalendarSyncToken == token {
+		t.Errorf("sync token was not replaced")
+	}
+	if gone.DueAt != nil || gone.CalendarEventID != "" {
+		t.Errorf("missing event did not clear its todo")
+	}
+	if kept.DueAt == nil || kept.CalendarEventID == "" {
+		t.Errorf("live event was unlinked")
+	}
+}
+
+func TestAdoptAfterHalfFailedPush(t *testing.T) {
+	store, cal, sync := setup(t)
+	todo := store.add("t1", due)
+	store.failLink = 1
+	if err := sync(); err == nil {
+		t.Fatal("want an error from the failed link")
+	}
+	if todo.CalendarEventID != "" {
+		t.Fatalf("todo linked despite the failure")
+	}
+
+	mustSync(t, sync)
+	events := cal.live()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	if todo.CalendarEventID != events[0].ID || todo.CalendarVersion != todo.Version {
+		t.Errorf("event not adopted: %+v", todo)
+	}
+}
+
+func TestAppWinsWhenBothChanged(t *testing.T) {
+	store, cal, sync := setup(t)
+	todo := store.add("t1", due)
+	mustSync(t, sync)
+
+	inApp := due.Add(24 * time.Hour)
+	store.edit("t1", inApp)
+	cal.move(t, todo.CalendarEventID, due.Add(2*time.Hour))
+	mustSync(t, sync)
+
+	if *todo.DueAt != inApp.UnixMilli() {
+		t.Errorf("todo due %v, want the app's %v", time.UnixMilli(*todo.DueAt).UTC(), inApp)
+	}
+	events := cal.live()
+	if len(events) != 1 || !eventStart(t, events[0]).Equal(inApp) {
+		t.Errorf("event not moved to the app's time: %+v", events)
+	}
+	if todo.CalendarVersion != todo.Version {
+		t.Errorf("todo not marked synced: %+v", todo)
+	}
+}
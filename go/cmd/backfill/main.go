@@ -0,0 +1,40 @@
+// Command backfill brings todos written before later schema additions up to date: it
+// gives them a rank, so they show up in lists, and puts open due ones in the due
+// index, so they get reminders. Run it once against the same environment as the
+// server, before starting a server that relies on either index:
+//
+//	go run ./cmd/backfill
+//
+// Running it again only touches todos that still need it.
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/juhun32/patriot25-gochi/go/api"
+	"github.com/juhun32/patriot25-gochi/go/aws"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+func main() {
+	ctx := context.Background()
+
+	cfg := api.Load()
+	dynamo := aws.NewDynamo(ctx, cfg.AWSRegion)
+
+	petRepo := repo.NewPetStateRepo(dynamo.Client, cfg.PetStatesTable, cfg.PetGrowth)
+	todoRepo := repo.NewTodoRepo(dynamo.Client, cfg.TodosTable, cfg.TodoTagsTable, cfg.TodoHistoryTable, cfg.ProjectsTable, cfg.TimeSessionsTable, cfg.SharesTable, petRepo, cfg.PetScoring, cfg.TrashRetention)
+
+	n, err := todoRepo.BackfillRanks(ctx)
+	if err != nil {
+		log.Fatalf("rank backfill stopped after ranking %d todos: %v", n, err)
+	}
+	log.Printf("ranked %d todos", n)
+
+	n, err = todoRepo.BackfillDueIndex(ctx)
+	if err != nil {
+		log.Fatalf("due index backfill stopped after adding %d todos: %v", n, err)
+	}
+	log.Printf("added %d todos to the due index", n)
+}
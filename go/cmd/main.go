@@ -7,10 +7,14 @@ import (
 
 	"github.com/juhun32/patriot25-gochi/go/api"
 	"github.com/juhun32/patriot25-gochi/go/aws"
+	"github.com/juhun32/patriot25-gochi/go/calsync"
 	"github.com/juhun32/patriot25-gochi/go/google"
 	"github.com/juhun32/patriot25-gochi/go/handlers"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/reminder"
 	"github.com/juhun32/patriot25-gochi/go/repo"
 	"github.com/juhun32/patriot25-gochi/go/route"
+	"github.com/juhun32/patriot25-gochi/pet/sim"
 )
 
 func main() {
@@ -21,9 +25,20 @@ func main() {
 
 	googleClient := google.New(cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.GoogleRedirectURL)
 	userRepo := repo.NewUserRepo(dynamo.Client, cfg.UsersTable)
+	petRepo := repo.NewPetStateRepo(dynamo.Client, cfg.PetStatesTable, cfg.PetGrowth)
+	todoRepo := repo.NewTodoRepo(dynamo.Client, cfg.TodosTable, cfg.TodoTagsTable, cfg.TodoHistoryTable, cfg.ProjectsTable, cfg.TimeSessionsTable, cfg.SharesTable, petRepo, cfg.PetScoring, cfg.TrashRetention)
+	reminderRepo := repo.NewReminderRepo(dynamo.Client, cfg.RemindersTable)
 	authHandler := api.NewAuthHandler(googleClient, userRepo, cfg.JWTSecret, "http://localhost:3000")
 
-	router := route.NewRouter(authHandler, handlers.NewTodosHandler((*repo.TodoRepo)(userRepo)), handlers.NewUserHandler((*repo.UserRepo)(userRepo)), cfg.JWTSecret)
+	reminders := reminder.NewService(todoRepo, userRepo, reminderRepo, reminder.LogNotifier{}, sim.SystemClock)
+	go reminders.Run(ctx, cfg.ReminderInterval)
+
+	calendarSync := calsync.NewService(todoRepo, userRepo, func(ctx context.Context, user *models.User) calsync.Calendar {
+		return google.NewCalendar(googleClient.Client(ctx, user.GoogleRefreshToken), cfg.CalendarAPIURL)
+	})
+	go calendarSync.Run(ctx, cfg.CalendarInterval)
+
+	router := route.NewRouter(authHandler, handlers.NewTodosHandler(todoRepo, userRepo), handlers.NewUserHandler(userRepo), handlers.NewPetHandler(todoRepo), cfg.JWTSecret)
 
 	addr := ":8080"
 	log.Println("Server listening on", addr)
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
 
+// CalendarEventsScope lets calendar sync manage events on the user's calendars.
+const CalendarEventsScope = "https://www.googleapis.com/auth/calendar.events"
+
 type GoogleOAuth struct {
 	config *oauth2.Config
 }
@@ -23,6 +27,7 @@ func New(clientID, clientSecret, redirectURL string) *GoogleOAuth {
 				"openid",
 				"email",
 				"profile",
+				CalendarEventsScope,
 			},
 			Endpoint: google.Endpoint,
 		},
@@ -33,6 +38,12 @@ func (g *GoogleOAuth) AuthCodeURL(state string) string {
 	return g.config.AuthCodeURL(state, oauth2.AccessTypeOffline)
 }
 
+// Client returns an HTTP client that authenticates as the user, refreshing access
+// tokens from refreshToken as they expire.
+func (g *GoogleOAuth) Client(ctx context.Context, refreshToken string) *http.Client {
+	return g.config.Client(ctx, &oauth2.Token{RefreshToken: refreshToken})
+}
+
 type GoogleUserInfo struct {
 	Sub           string `json:"sub"` // unique user id
 	Email         string `json:"email"`
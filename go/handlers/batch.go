@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+type batchOperation struct {
+	Op        string            `json:"op"`
+	ID        string            `json:"id"`
+	IfVersion *int64            `json:"ifVersion"`
+	Todo      createTodoRequest `json:"todo"`
+	Changes   updateTodoRequest `json:"changes"`
+}
+
+// BatchTodos applies several create/complete/update/delete operations in one request.
+// Results line up with the submitted operations. In transactional mode a rejected
+// batch is answered with 409 and nothing is written.
+func (h *TodosHandler) BatchTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Transactional bool             `json:"transactional"`
+		Operations    []batchOperation `json:"operations"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if len(body.Operations) > repo.MaxBatchOps {
+		http.Error(w, repo.ErrBatchTooLarge.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Invalid operations are answered here; the rest go to the repo and their results
+	// are mapped back to their original positions.
+	results := make([]repo.BatchResult, len(body.Operations))
+	var ops []repo.BatchOp
+	var positions []int
+	for i, in := range body.Operations {
+		op := repo.BatchOp{Op: in.Op, TodoID: in.ID, IfVersion: in.IfVersion}
+		var err error
+		switch in.Op {
+		case repo.BatchCreate:
+			op.Todo, err = in.Todo.toTodo(userID)
+		case repo.BatchUpdate:
+			op.Update, err = in.Changes.toUpdate()
+		}
+		if err != nil {
+			results[i] = repo.BatchResult{Op: in.Op, TodoID: in.ID, Error: err.Error()}
+			continue
+		}
+		ops = append(ops, op)
+		positions = append(positions, i)
+	}
+
+	status := http.StatusOK
+	if body.Transactional && len(ops) < len(body.Operations) {
+		for _, i := range positions {
+			results[i] = repo.BatchResult{Op: body.Operations[i].Op, TodoID: body.Operations[i].ID, Error: "not applied: batch aborted"}
+		}
+		status = http.StatusBadRequest
+	} else {
+		applied, err := h.TodoRepo.ApplyBatch(r.Context(), userID, ops, body.Transactional)
+		if errors.Is(err, repo.ErrBatchAborted) {
+			status = http.StatusConflict
+		} else if err != nil {
+			http.Error(w, "failed to apply batch: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for j, res := range applied {
+			results[positions[j]] = res
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"results": results})
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+)
+
+// maxEstimateWeeks bounds the range of an estimate report.
+const maxEstimateWeeks = 53
+
+// EstimateReport compares estimated with tracked time per week for estimated todos
+// completed between from and to, YYYY-MM-DD dates in the tz time zone (default UTC).
+// The range defaults to the last twelve weeks.
+func (h *TodosHandler) EstimateReport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	loc, err := time.LoadLocation(q.Get("tz"))
+	if err != nil {
+		http.Error(w, "invalid tz", http.StatusBadRequest)
+		return
+	}
+	to := time.Now().In(loc)
+	if v := q.Get("to"); v != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, v, loc); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -7*11)
+	if v := q.Get("from"); v != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, v, loc); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxEstimateWeeks*7*24*time.Hour {
+		http.Error(w, "range is too long", http.StatusBadRequest)
+		return
+	}
+
+	weeks, err := h.TodoRepo.EstimateReport(r.Context(), userID, from, to, loc)
+	if err != nil {
+		http.Error(w, "failed to compare estimates: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var estimated, tracked int64
+	for _, wk := range weeks {
+		estimated += wk.EstimatedMs
+		tracked += wk.TrackedMs
+	}
+	ratio := 0.0
+	if estimated > 0 {
+		ratio = float64(tracked) / float64(estimated)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"weeks":       weeks,
+		"estimatedMs": estimated,
+		"trackedMs":   tracked,
+		"ratio":       ratio,
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/ical"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+// CalendarFeed serves GET /feeds/{token}.ics. Calendar apps cannot sign in, so the
+// secret token in the path is the only credential; it is checked against the user
+// record as well as the index so a just-rotated token stops working at once.
+// ?as=todo serves VTODOs instead of the default VEVENTs.
+func (h *TodosHandler) CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+	if !ok || token == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByFeedToken(r.Context(), token)
+	if err != nil {
+		http.Error(w, "failed to load feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user != nil {
+		user, err = h.UserRepo.GetUserByID(r.Context(), user.UserID)
+		if err != nil {
+			http.Error(w, "failed to load feed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if user == nil || user.FeedToken != token {
+		http.NotFound(w, r)
+		return
+	}
+
+	page, err := h.TodoRepo.ListTodosPage(r.Context(), user.UserID, repo.TodoListOptions{IncludeShared: true})
+	if err != nil {
+		http.Error(w, "failed to load feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	name := "Gochi todos"
+	if user.Name != "" {
+		name = user.Name + "'s todos"
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	ical.WriteFeed(w, name, page.Todos, r.URL.Query().Get("as") != "todo", time.Now())
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+// GetTodoHistory authorizes from the history and the caller's shares rather than
+// through todoAccess, so a purged todo's history can still be read.
+func (h *TodosHandler) GetTodoHistory(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	entries, err := h.TodoRepo.TodoHistory(r.Context(), userID, r.PathValue("id"))
+	if errors.Is(err, repo.ErrTodoNotFound) {
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to fetch history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"history":       entries,
+		"postponements": models.Postponements(entries),
+	})
+}
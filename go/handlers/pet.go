@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+	"github.com/juhun32/patriot25-gochi/pet/sim"
+)
+
+type PetHandler struct {
+	TodoRepo *repo.TodoRepo // scores the pet against its owner's todos
+	Clock    sim.Clock
+}
+
+func NewPetHandler(todoRepo *repo.TodoRepo) *PetHandler {
+	return &PetHandler{
+		TodoRepo: todoRepo,
+		Clock:    sim.SystemClock,
+	}
+}
+
+// GetPet returns the caller's pet as of now, hatching it on first access. Its mood is
+// re-evaluated on every read, since todos going overdue and old work fading move the
+// completion score without any write. Clients pass their IANA time zone as ?tz so
+// streak days follow the owner when they travel.
+func (h *PetHandler) GetPet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	tz := r.URL.Query().Get("tz")
+	if _, err := time.LoadLocation(tz); err != nil {
+		http.Error(w, "invalid tz", http.StatusBadRequest)
+		return
+	}
+
+	now := h.Clock.Now()
+	state, err := h.TodoRepo.ScorePet(r.Context(), userID, now, func(p *models.PetState) bool {
+		return p.Visit(now, tz)
+	})
+	if err != nil {
+		http.Error(w, "failed to fetch pet: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state.Simulate(now)
+
+	writePet(w, state)
+}
+
+type updatePetRequest struct {
+	Timezone   *string `json:"timezone"`
+	StreakGoal *int    `json:"streakGoal"`
+}
+
+// UpdatePet changes the caller's streak settings.
+func (h *PetHandler) UpdatePet(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body updatePetRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if body.Timezone == nil && body.StreakGoal == nil {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+	if body.Timezone != nil {
+		if _, err := time.LoadLocation(*body.Timezone); err != nil || *body.Timezone == "" {
+			http.Error(w, "invalid timezone", http.StatusBadRequest)
+			return
+		}
+	}
+	if body.StreakGoal != nil {
+		if err := models.ValidateStreakGoal(*body.StreakGoal); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := h.Clock.Now()
+	state, err := h.TodoRepo.ModifyScoredPet(r.Context(), userID, now, func(p *models.PetState) error {
+		if body.Timezone != nil {
+			p.Timezone = *body.Timezone
+		}
+		if body.StreakGoal != nil {
+			p.StreakGoal = *body.StreakGoal
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "failed to update pet: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state.Simulate(now)
+
+	writePet(w, state)
+}
+
+func (h *PetHandler) FeedPet(w http.ResponseWriter, r *http.Request) {
+	h.petAction(w, r, sim.ActionFeed)
+}
+
+func (h *PetHandler) GiveTreat(w http.ResponseWriter, r *http.Request) {
+	h.petAction(w, r, sim.ActionTreat)
+}
+
+func (h *PetHandler) PutPetToSleep(w http.ResponseWriter, r *http.Request) {
+	h.petAction(w, r, sim.ActionSleep)
+}
+
+// petAction carries out action on the caller's pet now and returns the updated pet.
+func (h *PetHandler) petAction(w http.ResponseWriter, r *http.Request, action sim.Action) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	now := h.Clock.Now()
+	state, err := h.TodoRepo.ModifyScoredPet(r.Context(), userID, now, func(p *models.PetState) error {
+		return p.Apply(action, now)
+	})
+	if err != nil {
+		http.Error(w, "failed to update pet: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	state.Simulate(now)
+
+	writePet(w, state)
+}
+
+// writePet sends the pet with its stats rounded to whole points, as the desktop and
+// CLI show them. The stored stats keep their fractions so decay does not drift. Every
+// pet response carries the level-ups and evolutions the pet went through since the
+// last one, once, for the client to animate.
+func writePet(w http.ResponseWriter, state *models.PetState) {
+	state.Hunger = float64(sim.Round(state.Hunger))
+	state.Energy = float64(sim.Round(state.Energy))
+	state.Affection = float64(sim.Round(state.Affection))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+const maxProjectName = 100
+
+func validProjectName(name string) error {
+	if name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(name) > maxProjectName {
+		return fmt.Errorf("name is too long")
+	}
+	if strings.EqualFold(name, models.InboxProject) {
+		return fmt.Errorf("%q is reserved", models.InboxProject)
+	}
+	return nil
+}
+
+func (h *TodosHandler) ListProjects(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	includeArchived := r.URL.Query().Get("archived") == "true"
+	projects, err := h.TodoRepo.ListProjects(r.Context(), userID, includeArchived)
+	if err != nil {
+		http.Error(w, "failed to list projects: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"projects": projects})
+}
+
+type createProjectRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+func (h *TodosHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body createProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if err := validProjectName(body.Name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !models.ValidProjectColor(body.Color) {
+		http.Error(w, "invalid color", http.StatusBadRequest)
+		return
+	}
+
+	project := &models.Project{
+		UserID:    userID,
+		ProjectID: uuid.NewString(),
+		Name:      body.Name,
+		Color:     body.Color,
+	}
+	if err := h.TodoRepo.CreateProject(r.Context(), project); err != nil {
+		http.Error(w, "failed to create project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(project)
+}
+
+func (h *TodosHandler) GetProject(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	project, err := h.TodoRepo.GetProject(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "failed to get project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if project == nil {
+		http.Error(w, "project not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+type updateProjectRequest struct {
+	Name     *string `json:"name"`
+	Color    *string `json:"color"`
+	Archived *bool   `json:"archived"`
+}
+
+func (h *TodosHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body updateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if body.Name != nil {
+		name := strings.TrimSpace(*body.Name)
+		if err := validProjectName(name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body.Name = &name
+	}
+	if body.Color != nil && !models.ValidProjectColor(*body.Color) {
+		http.Error(w, "invalid color", http.StatusBadRequest)
+		return
+	}
+
+	upd := repo.ProjectUpdate{Name: body.Name, Color: body.Color, Archived: body.Archived}
+	project, err := h.TodoRepo.UpdateProject(r.Context(), userID, r.PathValue("id"), upd)
+	if errors.Is(err, repo.ErrProjectNotFound) {
+		http.Error(w, "project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// DeleteProject removes a project. ?todos=inbox (the default) keeps its todos in the
+// inbox; ?todos=cascade moves them to the trash along with it.
+func (h *TodosHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	mode := r.URL.Query().Get("todos")
+	if mode == "" {
+		mode = repo.ProjectDeleteInbox
+	}
+	if mode != repo.ProjectDeleteInbox && mode != repo.ProjectDeleteCascade {
+		http.Error(w, "todos must be inbox or cascade", http.StatusBadRequest)
+		return
+	}
+
+	err := h.TodoRepo.DeleteProject(r.Context(), userID, r.PathValue("id"), mode)
+	if errors.Is(err, repo.ErrProjectNotFound) {
+		http.Error(w, "project not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete project: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+// todoAccess resolves the {id} todo for the caller, who may own it or have it shared
+// with them, and checks that their role allows need. Otherwise it writes the error
+// response and returns ok=false. Callers address the todo with ownerID.
+func (h *TodosHandler) todoAccess(w http.ResponseWriter, r *http.Request, need string) (ownerID, role string, ok bool) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return "", "", false
+	}
+
+	ownerID, role, err := h.TodoRepo.TodoAccess(r.Context(), userID, r.PathValue("id"))
+	if errors.Is(err, repo.ErrTodoNotFound) {
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return "", "", false
+	}
+	if err != nil {
+		http.Error(w, "failed to check access: "+err.Error(), http.StatusInternalServerError)
+		return "", "", false
+	}
+	if !models.RoleAllows(role, need) {
+		http.Error(w, "your role on this todo does not allow that", http.StatusForbidden)
+		return "", "", false
+	}
+	return ownerID, role, true
+}
+
+// shareOwner checks that the caller owns the {id} item being shared.
+func (h *TodosHandler) shareOwner(w http.ResponseWriter, r *http.Request, kind string) (ownerID string, ok bool) {
+	if kind == models.ShareTodo {
+		ownerID, _, ok = h.todoAccess(w, r, models.RoleOwner)
+		return ownerID, ok
+	}
+
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+	project, err := h.TodoRepo.GetProject(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "failed to get project: "+err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+	if project == nil {
+		http.Error(w, "project not found", http.StatusNotFound)
+		return "", false
+	}
+	return userID, true
+}
+
+type shareRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+func (h *TodosHandler) createShare(w http.ResponseWriter, r *http.Request, kind string) {
+	ownerID, ok := h.shareOwner(w, r, kind)
+	if !ok {
+		return
+	}
+
+	var body shareRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if !models.ValidShareRole(body.Role) {
+		http.Error(w, "role must be viewer or editor", http.StatusBadRequest)
+		return
+	}
+
+	recipient, err := h.UserRepo.GetUserByEmail(r.Context(), strings.TrimSpace(body.Email))
+	if err != nil {
+		http.Error(w, "failed to look up user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if recipient == nil {
+		http.Error(w, "no user with that email", http.StatusNotFound)
+		return
+	}
+	if recipient.UserID == ownerID {
+		http.Error(w, "cannot share with yourself", http.StatusBadRequest)
+		return
+	}
+
+	share := &models.Share{
+		UserID:  recipient.UserID,
+		OwnerID: ownerID,
+		Kind:    kind,
+		ItemID:  r.PathValue("id"),
+		Role:    body.Role,
+		Email:   recipient.Email,
+	}
+	if err := h.TodoRepo.ShareItem(r.Context(), share); err != nil {
+		http.Error(w, "failed to share: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(share)
+}
+
+func (h *TodosHandler) listShares(w http.ResponseWriter, r *http.Request, kind string) {
+	ownerID, ok := h.shareOwner(w, r, kind)
+	if !ok {
+		return
+	}
+
+	shares, err := h.TodoRepo.ListShares(r.Context(), ownerID, kind, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "failed to list shares: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"shares": shares})
+}
+
+// revokeShare removes {userId}'s access to the item. The owner can revoke anyone; a
+// recipient can only remove themselves.
+func (h *TodosHandler) revokeShare(w http.ResponseWriter, r *http.Request, kind string) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	recipientID := r.PathValue("userId")
+	var ownerID string
+	if recipientID == userID {
+		var err error
+		ownerID, err = h.TodoRepo.SharedBy(r.Context(), userID, kind, r.PathValue("id"))
+		if errors.Is(err, repo.ErrShareNotFound) {
+			http.Error(w, "share not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to find share: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	} else if ownerID, ok = h.shareOwner(w, r, kind); !ok {
+		return
+	}
+
+	err := h.TodoRepo.RevokeShare(r.Context(), recipientID, ownerID, kind, r.PathValue("id"))
+	if errors.Is(err, repo.ErrShareNotFound) {
+		http.Error(w, "share not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to revoke share: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TodosHandler) ShareTodo(w http.ResponseWriter, r *http.Request) {
+	h.createShare(w, r, models.ShareTodo)
+}
+
+func (h *TodosHandler) ListTodoShares(w http.ResponseWriter, r *http.Request) {
+	h.listShares(w, r, models.ShareTodo)
+}
+
+func (h *TodosHandler) UnshareTodo(w http.ResponseWriter, r *http.Request) {
+	h.revokeShare(w, r, models.ShareTodo)
+}
+
+func (h *TodosHandler) ShareProject(w http.ResponseWriter, r *http.Request) {
+	h.createShare(w, r, models.ShareProject)
+}
+
+func (h *TodosHandler) ListProjectShares(w http.ResponseWriter, r *http.Request) {
+	h.listShares(w, r, models.ShareProject)
+}
+
+func (h *TodosHandler) UnshareProject(w http.ResponseWriter, r *http.Request) {
+	h.revokeShare(w, r, models.ShareProject)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+func (h *TodosHandler) AddSubtask(w http.ResponseWriter, r *http.Request) {
+	ownerID, _, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Text == "" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	sub := models.Subtask{SubtaskID: uuid.NewString(), Text: body.Text}
+	todo, err := h.TodoRepo.AddSubtask(r.Context(), ownerID, r.PathValue("id"), sub)
+	if err != nil {
+		writeSubtaskError(w, err)
+		return
+	}
+
+	writeTodo(w, http.StatusCreated, todo)
+}
+
+func (h *TodosHandler) UpdateSubtask(w http.ResponseWriter, r *http.Request) {
+	ownerID, _, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Text *string `json:"text"`
+		Done *bool   `json:"done"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	todo, err := h.TodoRepo.UpdateSubtask(r.Context(), ownerID, r.PathValue("id"), r.PathValue("subtaskId"), body.Text, body.Done)
+	if err != nil {
+		writeSubtaskError(w, err)
+		return
+	}
+
+	writeTodo(w, http.StatusOK, todo)
+}
+
+func (h *TodosHandler) DeleteSubtask(w http.ResponseWriter, r *http.Request) {
+	ownerID, _, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	todo, err := h.TodoRepo.DeleteSubtask(r.Context(), ownerID, r.PathValue("id"), r.PathValue("subtaskId"))
+	if err != nil {
+		writeSubtaskError(w, err)
+		return
+	}
+
+	writeTodo(w, http.StatusOK, todo)
+}
+
+func (h *TodosHandler) ReorderSubtasks(w http.ResponseWriter, r *http.Request) {
+	ownerID, _, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Order []string `json:"order"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	todo, err := h.TodoRepo.ReorderSubtasks(r.Context(), ownerID, r.PathValue("id"), body.Order)
+	if err != nil {
+		writeSubtaskError(w, err)
+		return
+	}
+
+	writeTodo(w, http.StatusOK, todo)
+}
+
+func writeSubtaskError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repo.ErrTodoNotFound):
+		http.Error(w, "todo not found", http.StatusNotFound)
+	case errors.Is(err, repo.ErrSubtaskNotFound):
+		http.Error(w, "subtask not found", http.StatusNotFound)
+	case errors.Is(err, repo.ErrPreconditionFailed):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, repo.ErrInvalidSubtaskOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to update subtasks: "+err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+// maxSummaryDays bounds the range of a time summary request.
+const maxSummaryDays = 92
+
+func (h *TodosHandler) GetTimer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	running, err := h.TodoRepo.RunningTimer(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to get timer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"timer": running})
+}
+
+func (h *TodosHandler) StartTimer(w http.ResponseWriter, r *http.Request) {
+	ownerID, _, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	userID, _ := middleware.GetUserID(r)
+	running, stopped, err := h.TodoRepo.StartTimer(r.Context(), userID, ownerID, r.PathValue("id"))
+	if errors.Is(err, repo.ErrTodoNotFound) {
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to start timer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"timer": running, "stopped": stopped})
+}
+
+// StopTimer needs no role on the todo: the timer is the caller's own, and losing access
+// to a shared todo should not leave it running.
+func (h *TodosHandler) StopTimer(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	session, err := h.TodoRepo.StopTimer(r.Context(), userID, r.PathValue("id"))
+	if errors.Is(err, repo.ErrNoTimerRunning) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to stop timer: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// TimeSummary reports tracked time per day. from and to are YYYY-MM-DD dates in the
+// tz time zone (default UTC); the range defaults to the last seven days.
+func (h *TodosHandler) TimeSummary(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	loc, err := time.LoadLocation(q.Get("tz"))
+	if err != nil {
+		http.Error(w, "invalid tz", http.StatusBadRequest)
+		return
+	}
+	to := time.Now().In(loc)
+	if v := q.Get("to"); v != "" {
+		if to, err = time.ParseInLocation(time.DateOnly, v, loc); err != nil {
+			http.Error(w, "invalid to", http.StatusBadRequest)
+			return
+		}
+	}
+	from := to.AddDate(0, 0, -6)
+	if v := q.Get("from"); v != "" {
+		if from, err = time.ParseInLocation(time.DateOnly, v, loc); err != nil {
+			http.Error(w, "invalid from", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+	if to.Sub(from) > maxSummaryDays*24*time.Hour {
+		http.Error(w, "range is too long", http.StatusBadRequest)
+		return
+	}
+
+	days, err := h.TodoRepo.DailyTimeSummary(r.Context(), userID, from, to, loc)
+	if err != nil {
+		http.Error(w, "failed to summarize time: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"days": days})
+}
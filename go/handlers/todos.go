@@ -2,20 +2,38 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/quickadd"
 	"github.com/juhun32/patriot25-gochi/go/repo"
 )
 
+// writeTodo responds with a single todo, exposing its version as the ETag so clients
+// can send it back in If-Match.
+func writeTodo(w http.ResponseWriter, status int, todo *models.Todo) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", middleware.ETag(todo.Version))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(todo)
+}
+
 type TodosHandler struct {
 	TodoRepo *repo.TodoRepo
+	UserRepo *repo.UserRepo // finds people to share with by email
 }
 
-func NewTodosHandler(repo *repo.TodoRepo) *TodosHandler {
-	return &TodosHandler{TodoRepo: repo}
+func NewTodosHandler(todoRepo *repo.TodoRepo, userRepo *repo.UserRepo) *TodosHandler {
+	return &TodosHandler{TodoRepo: todoRepo, UserRepo: userRepo}
 }
 
 func (h *TodosHandler) ListTodos(w http.ResponseWriter, r *http.Request) {
@@ -25,14 +43,198 @@ func (h *TodosHandler) ListTodos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	todos, err := h.TodoRepo.ListTodos(r.Context(), userID)
+	opts, err := parseListOptions(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := h.TodoRepo.ListTodosPage(r.Context(), userID, opts)
+	if errors.Is(err, repo.ErrInvalidCursor) {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to list todos: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	resp := map[string]interface{}{"todos": page.Todos}
+	if page.NextCursor != "" {
+		resp["nextCursor"] = page.NextCursor
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"todos": todos})
+	json.NewEncoder(w).Encode(resp)
+}
+
+const maxListLimit = 100
+
+// parseListOptions reads limit, cursor, done, dueBefore, dueAfter, tag, project and
+// shared from the query string. Todos shared with the caller are included unless
+// shared=false.
+func parseListOptions(r *http.Request) (repo.TodoListOptions, error) {
+	q := r.URL.Query()
+	opts := repo.TodoListOptions{Cursor: q.Get("cursor"), Project: q.Get("project"), IncludeShared: true}
+
+	if v := q.Get("shared"); v != "" {
+		shared, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid shared")
+		}
+		opts.IncludeShared = shared
+	}
+
+	if v := q.Get("tag"); v != "" {
+		tags, err := models.NormalizeTags([]string{v})
+		if err != nil || len(tags) != 1 {
+			return opts, fmt.Errorf("invalid tag")
+		}
+		opts.Tag = tags[0]
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return opts, fmt.Errorf("invalid limit")
+		}
+		if n > maxListLimit {
+			n = maxListLimit
+		}
+		opts.Limit = int32(n)
+	}
+	if v := q.Get("done"); v != "" {
+		done, err := strconv.ParseBool(v)
+		if err != nil {
+			return opts, fmt.Errorf("invalid done")
+		}
+		opts.Done = &done
+	}
+	var err error
+	if opts.DueBefore, err = queryMillis(q, "dueBefore"); err != nil {
+		return opts, err
+	}
+	if opts.DueAfter, err = queryMillis(q, "dueAfter"); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// queryMillis parses an optional unix-millisecond query parameter.
+func queryMillis(q url.Values, name string) (*int64, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	ms, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &ms, nil
+}
+
+// createTodoRequest is the body of POST /api/todos and of batch "create" operations.
+type createTodoRequest struct {
+	Text         string             `json:"text"`
+	Notes        string             `json:"notes"`
+	DueAt        *int64             `json:"dueAt"`
+	Subtasks     []string           `json:"subtasks"`
+	AutoComplete bool               `json:"autoComplete"`
+	Recurrence   *models.Recurrence `json:"recurrence"`
+	Priority     string             `json:"priority"`
+	Estimate     int                `json:"estimateMinutes"`
+	Tags         []string           `json:"tags"`
+	ProjectID    string             `json:"projectId"`
+
+	// Quick is free text like "pay rent tomorrow 5pm #home !high every month". Parsed
+	// fields fill in whatever the structured fields above leave empty.
+	Quick    string `json:"quick"`
+	Timezone string `json:"timezone"` // IANA zone Quick is read in; defaults to UTC
+}
+
+// toTodo validates the request and builds a new todo with fresh IDs.
+func (body createTodoRequest) toTodo(userID string) (*models.Todo, error) {
+	if body.Quick != "" {
+		loc, err := time.LoadLocation(body.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone")
+		}
+		parsed := quickadd.Parse(body.Quick, time.Now().In(loc))
+		if body.Text == "" {
+			body.Text = parsed.Text
+		}
+		if body.DueAt == nil {
+			body.DueAt = parsed.DueAt
+		}
+		if body.Priority == "" {
+			body.Priority = parsed.Priority
+		}
+		if body.Recurrence == nil {
+			body.Recurrence = parsed.Recurrence
+		}
+		body.Tags = append(body.Tags, parsed.Tags...)
+	}
+	if strings.TrimSpace(body.Text) == "" {
+		return nil, fmt.Errorf("todo text is required")
+	}
+
+	if !models.ValidPriority(body.Priority) {
+		return nil, fmt.Errorf("invalid priority")
+	}
+	if err := models.ValidateEstimate(body.Estimate); err != nil {
+		return nil, err
+	}
+	tags, err := models.NormalizeTags(body.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	todo := &models.Todo{
+		UserID:          userID,
+		TodoID:          uuid.NewString(),
+		Text:            body.Text,
+		Notes:           body.Notes,
+		DueAt:           body.DueAt,
+		AutoComplete:    body.AutoComplete,
+		Priority:        body.Priority,
+		EstimateMinutes: body.Estimate,
+		Tags:            tags,
+		ProjectID:       body.ProjectID,
+	}
+	for _, text := range body.Subtasks {
+		todo.Subtasks = append(todo.Subtasks, models.Subtask{SubtaskID: uuid.NewString(), Text: text})
+	}
+	if body.Recurrence != nil {
+		if err := prepareRecurrence(todo, body.Recurrence); err != nil {
+			return nil, err
+		}
+	}
+	return todo, nil
+}
+
+// ParseQuickAdd previews how a quick-add line would be interpreted without saving it.
+func (h *TodosHandler) ParseQuickAdd(w http.ResponseWriter, r *http.Request) {
+	if _, ok := middleware.GetUserID(r); !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var body struct {
+		Quick    string `json:"quick"`
+		Timezone string `json:"timezone"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	loc, err := time.LoadLocation(body.Timezone)
+	if err != nil {
+		http.Error(w, "invalid timezone", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quickadd.Parse(body.Quick, time.Now().In(loc)))
 }
 
 func (h *TodosHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
@@ -42,23 +244,271 @@ func (h *TodosHandler) CreateTodo(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var body struct {
-		Text  string `json:"text"`
-		DueAt *int64 `json:"dueAt"`
-	}
+	var body createTodoRequest
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		http.Error(w, "bad request", http.StatusBadRequest)
 		return
 	}
 
-	todoID := uuid.NewString()
-	todo, err := h.TodoRepo.CreateTodo(r.Context(), userID, todoID, body.Text, body.DueAt)
+	// Todos filed under a project shared with the caller belong to its owner.
+	ownerID, err := h.TodoRepo.ProjectOwner(r.Context(), userID, body.ProjectID)
+	if errors.Is(err, repo.ErrProjectNotFound) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to create todo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	todo, err := body.toTodo(ownerID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.TodoRepo.CreateTodo(r.Context(), todo)
+	if errors.Is(err, repo.ErrProjectNotFound) || errors.Is(err, repo.ErrProjectArchived) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "failed to create todo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	writeTodo(w, http.StatusCreated, todo)
+}
+
+// prepareRecurrence validates rec and makes todo the first occurrence of a new series.
+// A monthly rule without an explicit day is pinned to the first due date's day so that
+// clamping in short months does not drift the series.
+func prepareRecurrence(todo *models.Todo, rec *models.Recurrence) error {
+	if todo.DueAt == nil {
+		return fmt.Errorf("recurring todos need a dueAt")
+	}
+	if err := rec.Validate(); err != nil {
+		return err
+	}
+	if rec.Freq == models.FreqMonthly && rec.MonthDay == 0 {
+		loc, _ := time.LoadLocation(rec.TZ)
+		rec.MonthDay = time.UnixMilli(*todo.DueAt).In(loc).Day()
+	}
+	todo.Recurrence = rec
+	todo.SeriesID = todo.TodoID
+	todo.Occurrence = 1
+	return nil
+}
+
+func (h *TodosHandler) GetTodo(w http.ResponseWriter, r *http.Request) {
+	ownerID, role, ok := h.todoAccess(w, r, models.RoleViewer)
+	if !ok {
+		return
+	}
+
+	todo, err := h.TodoRepo.GetTodo(r.Context(), ownerID, r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "failed to fetch todo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if todo == nil {
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	}
+	if role != models.RoleOwner {
+		todo.Role = role
+	}
+
+	writeTodo(w, http.StatusOK, todo)
+}
+
+// updateTodoRequest is the body of PATCH /api/todos/{id} and of batch "update"
+// operations. dueAt is kept raw so an explicit null can clear the due date.
+type updateTodoRequest struct {
+	Text         *string         `json:"text"`
+	Notes        *string         `json:"notes"`
+	DueAt        json.RawMessage `json:"dueAt"`
+	Done         *bool           `json:"done"`
+	AutoComplete *bool           `json:"autoComplete"`
+	Priority     *string         `json:"priority"`
+	Estimate     *int            `json:"estimateMinutes"` // 0 clears the estimate
+	Tags         []string        `json:"tags"`
+	ProjectID    *string         `json:"projectId"` // "" moves the todo to the inbox
+}
+
+func (body updateTodoRequest) toUpdate() (repo.TodoUpdate, error) {
+	upd := repo.TodoUpdate{
+		Text:            body.Text,
+		Notes:           body.Notes,
+		Done:            body.Done,
+		AutoComplete:    body.AutoComplete,
+		Priority:        body.Priority,
+		EstimateMinutes: body.Estimate,
+		ProjectID:       body.ProjectID,
+	}
+	if upd.Priority != nil && !models.ValidPriority(*upd.Priority) {
+		return upd, fmt.Errorf("invalid priority")
+	}
+	if upd.EstimateMinutes != nil {
+		if err := models.ValidateEstimate(*upd.EstimateMinutes); err != nil {
+			return upd, err
+		}
+	}
+	if body.Tags != nil {
+		tags, err := models.NormalizeTags(body.Tags)
+		if err != nil {
+			return upd, err
+		}
+		upd.Tags = &tags
+	}
+	if len(body.DueAt) > 0 {
+		if string(body.DueAt) == "null" {
+			upd.ClearDueAt = true
+		} else {
+			var dueAt int64
+			if err := json.Unmarshal(body.DueAt, &dueAt); err != nil {
+				return upd, fmt.Errorf("invalid dueAt")
+			}
+			upd.DueAt = &dueAt
+		}
+	}
+	return upd, nil
+}
+
+func (h *TodosHandler) UpdateTodo(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r)
+	ownerID, role, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var body updateTodoRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	upd, err := body.toUpdate()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Moving a todo into a project shared with the caller hands it to the project's
+	// owner, which only the todo's own owner may do. The inbox stays where it is.
+	newOwnerID := ownerID
+	if upd.ProjectID != nil && *upd.ProjectID != "" {
+		newOwnerID, err = h.TodoRepo.ProjectOwner(r.Context(), userID, *upd.ProjectID)
+		if errors.Is(err, repo.ErrProjectNotFound) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to update todo: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if newOwnerID != ownerID && role != models.RoleOwner {
+			http.Error(w, "only the owner can move a todo to another user's project", http.StatusForbidden)
+			return
+		}
+	}
+
+	var todo *models.Todo
+	if newOwnerID != ownerID {
+		todo, err = h.TodoRepo.RefileTodo(r.Context(), ownerID, r.PathValue("id"), newOwnerID, upd)
+	} else {
+		todo, err = h.TodoRepo.UpdateTodo(r.Context(), ownerID, r.PathValue("id"), upd)
+	}
+	if errors.Is(err, repo.ErrTodoNotFound) {
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, repo.ErrPreconditionFailed) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if errors.Is(err, repo.ErrProjectNotFound) || errors.Is(err, repo.ErrProjectArchived) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update todo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeTodo(w, http.StatusOK, todo)
+}
+
+func (h *TodosHandler) DeleteTodo(w http.ResponseWriter, r *http.Request) {
+	ownerID, _, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	err := h.TodoRepo.DeleteTodo(r.Context(), ownerID, r.PathValue("id"))
+	if errors.Is(err, repo.ErrTodoNotFound) {
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, repo.ErrPreconditionFailed) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to delete todo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TodosHandler) ListTags(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tags, err := h.TodoRepo.ListTagCounts(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to list tags: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(todo)
+	json.NewEncoder(w).Encode(map[string]interface{}{"tags": tags})
+}
+
+func (h *TodosHandler) MoveTodo(w http.ResponseWriter, r *http.Request) {
+	ownerID, _, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Before string `json:"before"`
+		After  string `json:"after"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	todo, err := h.TodoRepo.MoveTodo(r.Context(), ownerID, r.PathValue("id"), body.Before, body.After)
+	switch {
+	case errors.Is(err, repo.ErrInvalidMove):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, repo.ErrTodoNotFound):
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	case errors.Is(err, repo.ErrPreconditionFailed):
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	case err != nil:
+		http.Error(w, "failed to move todo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeTodo(w, http.StatusOK, todo)
 }
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+	"github.com/juhun32/patriot25-gochi/go/transfer"
+)
+
+const (
+	maxImportBytes   = 5 << 20
+	maxImportRecords = 5000
+)
+
+var exportFiles = map[string]struct{ contentType, ext string }{
+	transfer.FormatJSON:    {"application/json", "json"},
+	transfer.FormatCSV:     {"text/csv; charset=utf-8", "csv"},
+	transfer.FormatTodoTxt: {"text/plain; charset=utf-8", "txt"},
+}
+
+// ExportTodos streams all of the caller's own todos in ?format=json|csv|todotxt
+// (default json), a page at a time.
+func (h *TodosHandler) ExportTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = transfer.FormatJSON
+	}
+	file, ok := exportFiles[format]
+	if !ok {
+		http.Error(w, transfer.ErrUnknownFormat.Error(), http.StatusBadRequest)
+		return
+	}
+
+	opts := repo.TodoListOptions{Limit: maxListLimit}
+	page, err := h.TodoRepo.ListTodosPage(r.Context(), userID, opts)
+	if err != nil {
+		http.Error(w, "failed to export todos: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", file.contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="todos.`+file.ext+`"`)
+	enc, _ := transfer.NewEncoder(w, format)
+	for {
+		for _, t := range page.Todos {
+			if err := enc.Encode(transfer.FromTodo(t)); err != nil {
+				return
+			}
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		// Headers are gone by now, so a failure can only cut the download short.
+		opts.Cursor = page.NextCursor
+		if page, err = h.TodoRepo.ListTodosPage(r.Context(), userID, opts); err != nil {
+			log.Println("export aborted:", err)
+			return
+		}
+	}
+	enc.Close()
+}
+
+// Import line statuses.
+const (
+	importCreate    = "create" // dry run: would be created
+	importCreated   = "created"
+	importDuplicate = "duplicate"
+	importInvalid   = "invalid"
+	importFailed    = "failed"
+)
+
+type importLine struct {
+	Line   int          `json:"line"`
+	Status string       `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Todo   *models.Todo `json:"todo,omitempty"`
+}
+
+// importKey identifies a todo for duplicate detection: the same text, ignoring case
+// and spacing, due at the same time.
+func importKey(t *models.Todo) string {
+	key := strings.ToLower(strings.Join(strings.Fields(t.Text), " "))
+	if t.DueAt != nil {
+		key += fmt.Sprintf("|%d", *t.DueAt)
+	}
+	return key
+}
+
+// ImportTodos reads todos from the request body in ?format=json|csv|todotxt. Todos that
+// duplicate an existing one or an earlier line are skipped, and every line gets a status
+// in the report. With ?dryRun=true nothing is written. Date-only due dates are read in
+// ?tz (default UTC).
+func (h *TodosHandler) ImportTodos(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	q := r.URL.Query()
+	format := q.Get("format")
+	if _, ok := exportFiles[format]; !ok {
+		http.Error(w, transfer.ErrUnknownFormat.Error(), http.StatusBadRequest)
+		return
+	}
+	dryRun := q.Get("dryRun") == "true"
+	loc, err := time.LoadLocation(q.Get("tz"))
+	if err != nil {
+		http.Error(w, "invalid tz", http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.TodoRepo.ListTodos(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to load todos: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	seen := make(map[string]bool, len(existing))
+	for i := range existing {
+		seen[importKey(&existing[i])] = true
+	}
+
+	errTooMany := fmt.Errorf("an import can hold at most %d todos", maxImportRecords)
+	var lines []importLine
+	var ops []repo.BatchOp
+	var opLines []int // index into lines for each op
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	err = transfer.Decode(body, format, loc, func(n int, rec transfer.Record, err error) error {
+		if len(lines) == maxImportRecords {
+			return errTooMany
+		}
+		line := importLine{Line: n}
+		var todo *models.Todo
+		if err == nil {
+			todo, err = rec.Todo(userID)
+		}
+		switch {
+		case err != nil:
+			line.Status, line.Error = importInvalid, err.Error()
+		case seen[importKey(todo)]:
+			line.Status, line.Todo = importDuplicate, todo
+		default:
+			seen[importKey(todo)] = true
+			todo.TodoID = uuid.NewString()
+			line.Status, line.Todo = importCreate, todo
+			ops = append(ops, repo.BatchOp{Op: repo.BatchCreate, Todo: todo})
+			opLines = append(opLines, len(lines))
+		}
+		lines = append(lines, line)
+		return nil
+	})
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "import file is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to read import: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !dryRun {
+		for start := 0; start < len(ops); start += repo.MaxBatchOps {
+			end := min(start+repo.MaxBatchOps, len(ops))
+			results, err := h.TodoRepo.ApplyBatch(r.Context(), userID, ops[start:end], false)
+			if err != nil {
+				http.Error(w, "failed to import todos: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for j, res := range results {
+				line := &lines[opLines[start+j]]
+				if res.OK {
+					line.Status, line.Todo = importCreated, res.Todo
+				} else {
+					line.Status, line.Error = importFailed, res.Error
+				}
+			}
+		}
+	}
+
+	counts := map[string]int{}
+	for _, l := range lines {
+		counts[l.Status]++
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dryRun": dryRun,
+		"counts": counts,
+		"lines":  lines,
+	})
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+func (h *TodosHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserID(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	todos, err := h.TodoRepo.ListTrash(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "failed to list trash: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"todos": todos})
+}
+
+func (h *TodosHandler) RestoreTodo(w http.ResponseWriter, r *http.Request) {
+	ownerID, _, ok := h.todoAccess(w, r, models.RoleEditor)
+	if !ok {
+		return
+	}
+
+	todo, err := h.TodoRepo.RestoreTodo(r.Context(), ownerID, r.PathValue("id"))
+	if errors.Is(err, repo.ErrTodoNotFound) {
+		http.Error(w, "todo not found in trash", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, repo.ErrPreconditionFailed) {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to restore todo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeTodo(w, http.StatusOK, todo)
+}
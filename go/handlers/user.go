@@ -6,6 +6,7 @@ import (
 
 	"github.com/juhun32/patriot25-gochi/go/api"
 	"github.com/juhun32/patriot25-gochi/go/middleware"
+	"github.com/juhun32/patriot25-gochi/go/models"
 	"github.com/juhun32/patriot25-gochi/go/repo"
 )
 
@@ -35,3 +36,108 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
+
+type updateUserRequest struct {
+	ReminderLeadMinutes *[]int `json:"reminderLeadMinutes"`
+}
+
+func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserClaimsKey()).(*api.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "missing auth claims", http.StatusUnauthorized)
+		return
+	}
+
+	var body updateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if body.ReminderLeadMinutes == nil {
+		http.Error(w, "nothing to update", http.StatusBadRequest)
+		return
+	}
+	leads, err := models.NormalizeReminderLeads(*body.ReminderLeadMinutes)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.UserRepo.SetReminderLeads(r.Context(), claims.UserID, leads)
+	if err != nil {
+		http.Error(w, "failed to update user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user == nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// feedURL is the address calendar apps subscribe to, on the host the request came in on.
+func feedURL(r *http.Request, token string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/feeds/" + token + ".ics"
+}
+
+// GetFeed returns the user's calendar feed URL, or 404 if the feed is off.
+func (h *UserHandler) GetFeed(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserClaimsKey()).(*api.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "missing auth claims", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.UserRepo.GetUserByID(r.Context(), claims.UserID)
+	if err != nil {
+		http.Error(w, "failed to fetch user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if user == nil || user.FeedToken == "" {
+		http.Error(w, "calendar feed is off", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": feedURL(r, user.FeedToken)})
+}
+
+// RotateFeed turns the calendar feed on, or moves it to a new URL so the old one stops
+// working.
+func (h *UserHandler) RotateFeed(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserClaimsKey()).(*api.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "missing auth claims", http.StatusUnauthorized)
+		return
+	}
+
+	token, err := h.UserRepo.RotateFeedToken(r.Context(), claims.UserID)
+	if err != nil {
+		http.Error(w, "failed to rotate feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"url": feedURL(r, token)})
+}
+
+func (h *UserHandler) DeleteFeed(w http.ResponseWriter, r *http.Request) {
+	claims, ok := r.Context().Value(middleware.UserClaimsKey()).(*api.Claims)
+	if !ok || claims == nil {
+		http.Error(w, "missing auth claims", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.UserRepo.RevokeFeedToken(r.Context(), claims.UserID); err != nil {
+		http.Error(w, "failed to turn off feed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
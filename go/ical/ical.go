@@ -0,0 +1,130 @@
+// Package ical renders todos as an RFC 5545 iCalendar feed.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// maxLineOctets is the longest a content line may be before it must be folded.
+const maxLineOctets = 75
+
+const (
+	dateTimeUTC   = "20060102T150405Z"
+	eventDuration = "PT30M"
+)
+
+var priorities = map[string]int{
+	models.PriorityHigh:   1,
+	models.PriorityMedium: 5,
+	models.PriorityLow:    9,
+}
+
+// Escape escapes a TEXT value: backslashes, semicolons, commas and newlines.
+func Escape(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`, "\r", `\n`).Replace(s)
+}
+
+// Fold splits a content line into chunks of at most 75 octets joined by CRLF and a
+// space, never breaking a UTF-8 sequence.
+func Fold(line string) string {
+	if len(line) <= maxLineOctets {
+		return line
+	}
+	var b strings.Builder
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLineOctets - 1 // the leading space counts
+	}
+	b.WriteString(line)
+	return b.String()
+}
+
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (w *writer) line(name, value string) {
+	if w.err == nil {
+		_, w.err = w.w.WriteString(Fold(name+":"+value) + "\r\n")
+	}
+}
+
+// WriteFeed writes a calendar named name holding the todos that have a due time, as
+// VEVENTs when asEvents is set (most calendar apps ignore VTODO) and VTODOs otherwise.
+func WriteFeed(out io.Writer, name string, todos []models.Todo, asEvents bool, now time.Time) error {
+	w := &writer{w: bufio.NewWriter(out)}
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", "-//Gochi//Todos//EN")
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	w.line("X-WR-CALNAME", Escape(name))
+
+	for _, t := range todos {
+		if t.DueAt == nil {
+			continue
+		}
+		due := time.UnixMilli(*t.DueAt).UTC().Format(dateTimeUTC)
+		component := "VTODO"
+		if asEvents {
+			component = "VEVENT"
+		}
+
+		w.line("BEGIN", component)
+		w.line("UID", t.TodoID+"@gochi")
+		w.line("DTSTAMP", now.UTC().Format(dateTimeUTC))
+		w.line("LAST-MODIFIED", time.UnixMilli(t.UpdatedAt).UTC().Format(dateTimeUTC))
+		summary := t.Text
+		if asEvents {
+			w.line("DTSTART", due)
+			w.line("DURATION", eventDuration)
+			if t.Done {
+				summary = "✓ " + summary
+			}
+		} else {
+			w.line("DUE", due)
+			if t.Done {
+				w.line("STATUS", "COMPLETED")
+			} else {
+				w.line("STATUS", "NEEDS-ACTION")
+			}
+		}
+		w.line("SUMMARY", Escape(summary))
+		if t.Notes != "" {
+			w.line("DESCRIPTION", Escape(t.Notes))
+		}
+		if p, ok := priorities[t.Priority]; ok {
+			w.line("PRIORITY", fmt.Sprint(p))
+		}
+		if len(t.Tags) > 0 {
+			tags := make([]string, len(t.Tags))
+			for i, tag := range t.Tags {
+				tags[i] = Escape(tag)
+			}
+			w.line("CATEGORIES", strings.Join(tags, ","))
+		}
+		w.line("END", component)
+	}
+
+	w.line("END", "VCALENDAR")
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/juhun32/patriot25-gochi/go/api"
+	"github.com/juhun32/patriot25-gochi/go/repo"
 )
 
 type ctxKey string
@@ -30,16 +31,16 @@ func AuthMiddleware(secret string, next http.Handler) http.Handler {
 		}
 
 		ctx := context.WithValue(r.Context(), userClaimsKey, claims)
+		ctx = repo.WithActor(ctx, claims.UserID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
 // Helper to get userId in handlers
 func GetUserID(r *http.Request) (string, bool) {
-	v := r.Context().Value(userClaimsKey)
-	if v == nil {
+	claims, ok := r.Context().Value(userClaimsKey).(*api.Claims)
+	if !ok || claims == nil {
 		return "", false
 	}
-	id, ok := v.(string)
-	return id, ok
+	return claims.UserID, true
 }
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/juhun32/patriot25-gochi/go/repo"
+)
+
+// Preconditions turns an If-Match header carrying a todo ETag into a version pin for
+// the repo, so any write made while serving the request fails with 412 if the todo
+// changed since the client read it. "*" and an absent header leave writes unpinned.
+func Preconditions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := strings.TrimSpace(r.Header.Get("If-Match"))
+		if header == "" || header == "*" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid If-Match header", http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(repo.WithIfMatch(r.Context(), version)))
+	})
+}
+
+// ETag formats a todo version for the ETag header.
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxEstimateMinutes bounds an effort estimate at a working week.
+const MaxEstimateMinutes = 40 * 60
+
+// A todo estimated at baselineEstimateMinutes, or not estimated at all, is worth one
+// completion. Bigger todos are worth more with diminishing returns, so a single huge
+// todo cannot stand in for a week of work, and tiny ones are never worth nothing.
+const (
+	baselineEstimateMinutes = 30
+	minEffortWeight         = 0.5
+	maxEffortWeight         = 4
+)
+
+func ValidateEstimate(minutes int) error {
+	if minutes < 0 || minutes > MaxEstimateMinutes {
+		return fmt.Errorf("estimateMinutes must be between 0 and %d", MaxEstimateMinutes)
+	}
+	return nil
+}
+
+// EffortWeight is how much completing t counts toward the pet's completion score.
+func (t Todo) EffortWeight() float64 {
+	if t.EstimateMinutes <= 0 {
+		return 1
+	}
+	w := math.Sqrt(float64(t.EstimateMinutes) / baselineEstimateMinutes)
+	return min(max(w, minEffortWeight), maxEffortWeight)
+}
+
+// EstimateWeek compares estimated with tracked time for the estimated todos completed
+// in one week. Only todos with tracked time go into the totals and Ratio; the rest are
+// counted in Untracked.
+type EstimateWeek struct {
+	WeekStart   string  `json:"weekStart"` // the Monday, YYYY-MM-DD in the requested time zone
+	Todos       int     `json:"todos"`
+	Untracked   int     `json:"untracked"`
+	EstimatedMs int64   `json:"estimatedMs"`
+	TrackedMs   int64   `json:"trackedMs"`
+	Ratio       float64 `json:"ratio"` // tracked / estimated; above 1 means underestimated
+}
@@ -0,0 +1,185 @@
+package models
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+// Growth is the XP curve: what completions and streak milestones earn, how much XP
+// each level takes, and the level at which the pet reaches each evolution stage. The
+// default lives in growth.json; LoadGrowth reads a replacement.
+type Growth struct {
+	CompletionXP     int64             `json:"completionXp"` // for a todo of effort weight 1
+	StreakMilestones []StreakMilestone `json:"streakMilestones"`
+	Levels           []int64           `json:"levels"` // total XP to reach level 1, 2, ...; starts at 0
+	Stages           []Stage           `json:"stages"` // in order, the first at level 1
+}
+
+type StreakMilestone struct {
+	Days int64 `json:"days"`
+	XP   int64 `json:"xp"`
+}
+
+type Stage struct {
+	Name  string `json:"name"`
+	Level int    `json:"level"`
+}
+
+// Kinds of PetEvent.
+const (
+	PetEventLevelUp = "levelUp"
+	PetEventEvolved = "evolved"
+)
+
+// PetEvent is a level-up or evolution waiting to be shown to the owner.
+type PetEvent struct {
+	Kind  string `dynamodbav:"kind" json:"kind"`
+	Level int    `dynamodbav:"level" json:"level"`
+	Stage string `dynamodbav:"stage" json:"stage"`
+}
+
+// maxPetEvents bounds the events a pet keeps while nobody collects them.
+const maxPetEvents = 10
+
+//go:embed growth.json
+var defaultGrowth []byte
+
+var DefaultGrowth = mustParseGrowth(defaultGrowth)
+
+// LoadGrowth reads an XP curve in the format of growth.json from path.
+func LoadGrowth(path string) (Growth, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return Growth{}, err
+	}
+	return parseGrowth(raw)
+}
+
+func parseGrowth(raw []byte) (Growth, error) {
+	var g Growth
+	if err := json.Unmarshal(raw, &g); err != nil {
+		return Growth{}, err
+	}
+	if err := g.Validate(); err != nil {
+		return Growth{}, err
+	}
+	return g, nil
+}
+
+func mustParseGrowth(raw []byte) Growth {
+	g, err := parseGrowth(raw)
+	if err != nil {
+		panic(fmt.Sprintf("growth.json: %v", err))
+	}
+	return g
+}
+
+func (g Growth) Validate() error {
+	if g.CompletionXP < 0 {
+		return errors.New("completionXp cannot be negative")
+	}
+	for i, m := range g.StreakMilestones {
+		if m.Days <= 0 || m.XP < 0 || (i > 0 && m.Days <= g.StreakMilestones[i-1].Days) {
+			return errors.New("streak milestones need increasing positive days and non-negative xp")
+		}
+	}
+	if len(g.Levels) == 0 || g.Levels[0] != 0 {
+		return errors.New("levels must start at 0")
+	}
+	for i := 1; i < len(g.Levels); i++ {
+		if g.Levels[i] <= g.Levels[i-1] {
+			return errors.New("levels must increase")
+		}
+	}
+	if len(g.Stages) == 0 || g.Stages[0].Level != 1 {
+		return errors.New("the first stage must start at level 1")
+	}
+	for i, s := range g.Stages {
+		if s.Name == "" || s.Level > len(g.Levels) || (i > 0 && s.Level <= g.Stages[i-1].Level) {
+			return errors.New("stages need names and increasing levels no higher than the top level")
+		}
+	}
+	return nil
+}
+
+// CompletionXPFor is what completing t earns, scaled by its effort weight.
+func (g Growth) CompletionXPFor(t *Todo) int64 {
+	return int64(math.Round(float64(g.CompletionXP) * t.EffortWeight()))
+}
+
+// StreakXP is what a streak growing from before to after days earns in milestones.
+func (g Growth) StreakXP(before, after int64) int64 {
+	var xp int64
+	for _, m := range g.StreakMilestones {
+		if before < m.Days && m.Days <= after {
+			xp += m.XP
+		}
+	}
+	return xp
+}
+
+// Level is the level xp reaches.
+func (g Growth) Level(xp int64) int {
+	level := 0
+	for _, need := range g.Levels {
+		if xp >= need {
+			level++
+		}
+	}
+	return level
+}
+
+// Stage is the evolution stage of a pet at level.
+func (g Growth) Stage(level int) string {
+	name := ""
+	for _, s := range g.Stages {
+		if level >= s.Level {
+			name = s.Name
+		}
+	}
+	return name
+}
+
+// NextLevelXP is the total XP the level after level takes, or 0 at the top level.
+func (g Growth) NextLevelXP(level int) int64 {
+	if level >= len(g.Levels) {
+		return 0
+	}
+	return g.Levels[level]
+}
+
+// Gain adds xp, which may be negative, and moves the pet up to the level and stage
+// its XP reaches, queueing an event for each change. Levels are never lost, so taking
+// XP back cannot make a pet level up twice for the same work. A pet that has no level
+// yet is placed without events.
+func (p *PetState) Gain(g Growth, xp int64) {
+	p.XP = max(p.XP+xp, 0)
+	level := max(g.Level(p.XP), p.Level)
+	stage := g.Stage(level)
+	if p.Level > 0 && level > p.Level {
+		p.queueEvent(PetEvent{Kind: PetEventLevelUp, Level: level, Stage: stage})
+	}
+	if p.Stage != "" && stage != p.Stage {
+		p.queueEvent(PetEvent{Kind: PetEventEvolved, Level: level, Stage: stage})
+	}
+	p.Level, p.Stage = level, stage
+	p.NextLevelXP = g.NextLevelXP(level)
+}
+
+func (p *PetState) queueEvent(e PetEvent) {
+	p.Events = append(p.Events, e)
+	if len(p.Events) > maxPetEvents {
+		p.Events = p.Events[len(p.Events)-maxPetEvents:]
+	}
+}
+
+// TakeEvents returns the queued events and clears them.
+func (p *PetState) TakeEvents() []PetEvent {
+	events := p.Events
+	p.Events = nil
+	return events
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGrowthCurve(t *testing.T) {
+	g := DefaultGrowth
+	for i, need := range g.Levels {
+		if got := g.Level(need); got != i+1 {
+			t.Errorf("Level(%d) = %d, want %d", need, got, i+1)
+		}
+		if i > 0 {
+			if got := g.Level(need - 1); got != i {
+				t.Errorf("Level(%d) = %d, want %d", need-1, got, i)
+			}
+		}
+	}
+	for _, tc := range []struct {
+		level int
+		stage string
+		next  int64
+	}{
+		{1, "puppy", 50},
+		{3, "puppy", 220},
+		{4, "adult", 350},
+		{8, "golden-retriever", 1320},
+		{12, "golden-retriever", 0},
+	} {
+		if got := g.Stage(tc.level); got != tc.stage {
+			t.Errorf("Stage(%d) = %q, want %q", tc.level, got, tc.stage)
+		}
+		if got := g.NextLevelXP(tc.level); got != tc.next {
+			t.Errorf("NextLevelXP(%d) = %d, want %d", tc.level, got, tc.next)
+		}
+	}
+}
+
+func TestGain(t *testing.T) {
+	g := DefaultGrowth
+	levelUp := func(level int, stage string) PetEvent {
+		return PetEvent{Kind: PetEventLevelUp, Level: level, Stage: stage}
+	}
+	evolved := func(level int, stage string) PetEvent {
+		return PetEvent{Kind: PetEventEvolved, Level: level, Stage: stage}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		gains  []int64
+		level  int
+		stage  string
+		next   int64
+		events []PetEvent
+	}{
+		{
+			name:  "placing a new pet",
+			gains: []int64{0},
+			level: 1, stage: "puppy", next: 50,
+		},
+		{
+			name:  "short of a level",
+			gains: []int64{0, 49},
+			level: 1, stage: "puppy", next: 50,
+		},
+		{
+			name:  "one level",
+			gains: []int64{0, 50},
+			level: 2, stage: "puppy", next: 120,
+			events: []PetEvent{levelUp(2, "puppy")},
+		},
+		{
+			name:  "several levels and a stage at once",
+			gains: []int64{0, 400},
+			level: 5, stage: "adult", next: 520,
+			events: []PetEvent{levelUp(5, "adult"), evolved(5, "adult")},
+		},
+		{
+			name:  "levels are kept when XP is taken back",
+			gains: []int64{0, 60, -30, 30},
+			level: 2, stage: "puppy", next: 120,
+			events: []PetEvent{levelUp(2, "puppy")},
+		},
+		{
+			name:  "XP cannot go negative",
+			gains: []int64{0, -10, 50},
+			level: 2, stage: "puppy", next: 120,
+			events: []PetEvent{levelUp(2, "puppy")},
+		},
+		{
+			name:  "the top level",
+			gains: []int64{0, 2700, 100000},
+			level: 12, stage: "golden-retriever", next: 0,
+			events: []PetEvent{levelUp(12, "golden-retriever"), evolved(12, "golden-retriever")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var p PetState
+			for _, xp := range tc.gains {
+				p.Gain(g, xp)
+			}
+			if p.Level != tc.level || p.Stage != tc.stage || p.NextLevelXP != tc.next {
+				t.Errorf("level %d %q next %d, want %d %q next %d", p.Level, p.Stage, p.NextLevelXP, tc.level, tc.stage, tc.next)
+			}
+			if got := p.TakeEvents(); !reflect.DeepEqual(got, tc.events) {
+				t.Errorf("events %+v, want %+v", got, tc.events)
+			}
+			if got := p.TakeEvents(); got != nil {
+				t.Errorf("events %+v taken twice", got)
+			}
+		})
+	}
+}
+
+func TestEventsKeptWhileUncollected(t *testing.T) {
+	g := DefaultGrowth
+	var p PetState
+	p.Gain(g, 0)
+	for _, need := range g.Levels[1:] {
+		p.Gain(g, need-p.XP)
+	}
+
+	// 11 level-ups and 2 evolutions, of which the oldest 3 were dropped.
+	events := p.TakeEvents()
+	first := PetEvent{Kind: PetEventEvolved, Level: 4, Stage: "adult"}
+	last := PetEvent{Kind: PetEventLevelUp, Level: 12, Stage: "golden-retriever"}
+	if len(events) != maxPetEvents || events[0] != first || events[len(events)-1] != last {
+		t.Errorf("kept %+v, want %d from %+v to %+v", events, maxPetEvents, first, last)
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+const (
+	HistoryCreated  = "created"
+	HistoryUpdated  = "updated"
+	HistoryDeleted  = "deleted"
+	HistoryRestored = "restored"
+)
+
+// TodoHistoryEntry is an immutable record of one change to a todo.
+type TodoHistoryEntry struct {
+	UserID     string        `dynamodbav:"userId" json:"-"`
+	HistoryKey string        `dynamodbav:"historyKey" json:"-"` // "<todoId>#<at>#<entryId>"
+	TodoID     string        `dynamodbav:"todoId" json:"todoId"`
+	Action     string        `dynamodbav:"action" json:"action"` // created | updated | deleted | restored
+	Actor      string        `dynamodbav:"actor" json:"actor"`
+	At         int64         `dynamodbav:"at" json:"at"` // unix ms
+	Changes    []FieldChange `dynamodbav:"changes,omitempty" json:"changes,omitempty"`
+
+	// ProjectID is the project the todo was filed under after the change, so project
+	// shares still cover the history once the todo is purged. Older entries lack it.
+	ProjectID string `dynamodbav:"projectId,omitempty" json:"-"`
+}
+
+// FieldChange holds the before and after value of one todo attribute; nil means unset.
+type FieldChange struct {
+	Field string `dynamodbav:"field" json:"field"`
+	From  any    `dynamodbav:"from" json:"from"`
+	To    any    `dynamodbav:"to" json:"to"`
+}
+
+// Postponements counts how often the due date was pushed later.
+func Postponements(entries []TodoHistoryEntry) int {
+	n := 0
+	for _, e := range entries {
+		for _, c := range e.Changes {
+			if c.Field != "dueAt" {
+				continue
+			}
+			from, okFrom := c.From.(float64)
+			to, okTo := c.To.(float64)
+			if okFrom && okTo && to > from {
+				n++
+			}
+		}
+	}
+	return n
+}
@@ -1,11 +1,78 @@
 package models
 
+import (
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/pet/sim"
+)
+
 type PetState struct {
-	UserID            string  `dynamodbav:"userId"`
-	Mood              string  `dynamodbav:"mood"`        // grumpy | neutral | golden
-	Personality       string  `dynamodbav:"personality"` // supportive | sarcastic | chill
-	StreakDays        int64   `dynamodbav:"streakDays"`
-	LastLoginDay      string  `dynamodbav:"lastLoginDay"`      // e.g. "2025-11-14"
-	LastInteractionAt int64   `dynamodbav:"lastInteractionAt"` // unix ms
-	CompletionScore   float64 `dynamodbav:"completionScore"`   // optional aggregate
+	UserID            string  `dynamodbav:"userId" json:"userId"`
+	Mood              string  `dynamodbav:"mood" json:"mood"`                             // grumpy | neutral | golden
+	WorkMood          string  `dynamodbav:"workMood,omitempty" json:"workMood,omitempty"` // the mood CompletionScore alone earns
+	Personality       string  `dynamodbav:"personality" json:"personality"`               // supportive | sarcastic | chill
+	LastLoginDay      string  `dynamodbav:"lastLoginDay" json:"lastLoginDay"`             // e.g. "2025-11-14"
+	LastInteractionAt int64   `dynamodbav:"lastInteractionAt" json:"lastInteractionAt"`   // unix ms
+	CompletionScore   float64 `dynamodbav:"completionScore" json:"completionScore"`       // 0 to 1, see Scoring
+	Version           int64   `dynamodbav:"version" json:"-"`                             // bumped on every write
+
+	// Timezone (IANA) is where the owner is, and decides their calendar days. A day
+	// with at least StreakGoal completions extends the streak. StreakDays runs up to
+	// LastStreakDay, which ended at LastStreakDayEnd; StreakDayCount counts the
+	// completions on StreakDay, the day in progress.
+	Timezone         string `dynamodbav:"timezone,omitempty" json:"timezone,omitempty"`
+	StreakGoal       int    `dynamodbav:"streakGoal,omitempty" json:"streakGoal,omitempty"`
+	StreakDays       int64  `dynamodbav:"streakDays" json:"streakDays"`
+	BestStreakDays   int64  `dynamodbav:"bestStreakDays" json:"bestStreakDays"`
+	LastStreakDay    string `dynamodbav:"lastStreakDay,omitempty" json:"lastStreakDay,omitempty"`
+	LastStreakDayEnd int64  `dynamodbav:"lastStreakDayEnd,omitempty" json:"-"`
+	StreakDay        string `dynamodbav:"streakDay,omitempty" json:"streakDay,omitempty"`
+	StreakDayCount   int    `dynamodbav:"streakDayCount,omitempty" json:"streakDayCount"`
+
+	// XP earned from completed todos and streak milestones decides the pet's Level and
+	// evolution Stage, as set out by a Growth. Level-ups and evolutions wait in Events
+	// until the next response that shows the owner their pet delivers them.
+	XP          int64      `dynamodbav:"xp" json:"xp"`
+	Level       int        `dynamodbav:"level" json:"level"`
+	Stage       string     `dynamodbav:"stage,omitempty" json:"stage"`
+	NextLevelXP int64      `dynamodbav:"nextLevelXp,omitempty" json:"nextLevelXp,omitempty"` // 0 at the top level
+	Events      []PetEvent `dynamodbav:"events,omitempty" json:"events,omitempty"`
+
+	// ScoreSums let todo writes move CompletionScore without listing every todo.
+	ScoreSums *ScoreSums `dynamodbav:"scoreSums,omitempty" json:"-"`
+
+	// Stats are the pet's needs, simulated the same way as on the desktop.
+	sim.Stats
+}
+
+// Hatch gives a new pet its starting stats, keeping anything already recorded for it.
+func (p *PetState) Hatch(now time.Time) {
+	p.Stats = sim.New(now)
+	p.updateMood()
+	if p.Personality == "" {
+		p.Personality = "supportive"
+	}
+}
+
+// Simulate brings the pet up to now, including dropping a streak that has lapsed.
+func (p *PetState) Simulate(now time.Time) {
+	p.Stats = p.At(now)
+	p.updateMood()
+	p.StreakDays = p.CurrentStreak(now)
+	p.StreakGoal = p.Goal()
+	if p.StreakDay < now.In(p.Location()).Format(time.DateOnly) {
+		p.StreakDayCount = 0
+	}
+}
+
+// Apply carries out a care action at now.
+func (p *PetState) Apply(action sim.Action, now time.Time) error {
+	stats, err := p.Stats.Apply(action, now)
+	if err != nil {
+		return err
+	}
+	p.Stats = stats
+	p.updateMood()
+	p.LastInteractionAt = now.UnixMilli()
+	return nil
 }
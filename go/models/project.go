@@ -0,0 +1,24 @@
+package models
+
+import "regexp"
+
+// InboxProject selects todos that belong to no project when filtering by project.
+const InboxProject = "inbox"
+
+var projectColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ValidProjectColor accepts an empty color or a "#rrggbb" hex color.
+func ValidProjectColor(c string) bool {
+	return c == "" || projectColor.MatchString(c)
+}
+
+// Project is a named list that todos can be filed under; todos without one are in the inbox.
+type Project struct {
+	UserID    string `dynamodbav:"userId" json:"userId"`
+	ProjectID string `dynamodbav:"projectId" json:"projectId"`
+	Name      string `dynamodbav:"name" json:"name"`
+	Color     string `dynamodbav:"color,omitempty" json:"color,omitempty"`
+	Archived  bool   `dynamodbav:"archived" json:"archived"`
+	CreatedAt int64  `dynamodbav:"createdAt" json:"createdAt"`
+	UpdatedAt int64  `dynamodbav:"updatedAt" json:"updatedAt"`
+}
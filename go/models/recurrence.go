@@ -0,0 +1,129 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	FreqDaily   = "daily"
+	FreqWeekly  = "weekly"
+	FreqMonthly = "monthly"
+)
+
+// Recurrence is a small subset of RFC 5545 RRULE: FREQ, INTERVAL, BYDAY (weekly),
+// BYMONTHDAY (monthly), UNTIL and COUNT. "Every N days" is daily with Interval N.
+type Recurrence struct {
+	Freq     string   `dynamodbav:"freq" json:"freq"`                             // daily | weekly | monthly
+	Interval int      `dynamodbav:"interval,omitempty" json:"interval,omitempty"` // defaults to 1
+	Weekdays []string `dynamodbav:"weekdays,omitempty" json:"weekdays,omitempty"` // MO TU WE TH FR SA SU
+	MonthDay int      `dynamodbav:"monthDay,omitempty" json:"monthDay,omitempty"` // 1-31, or -1 for the last day
+	Until    *int64   `dynamodbav:"until,omitempty" json:"until,omitempty"`       // unix ms, inclusive
+	Count    int      `dynamodbav:"count,omitempty" json:"count,omitempty"`       // total occurrences
+	TZ       string   `dynamodbav:"tz,omitempty" json:"tz,omitempty"`             // IANA zone, defaults to UTC
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+func (rec Recurrence) Validate() error {
+	switch rec.Freq {
+	case FreqDaily, FreqWeekly, FreqMonthly:
+	default:
+		return errors.New("recurrence freq must be daily, weekly or monthly")
+	}
+	if rec.Interval < 0 {
+		return errors.New("recurrence interval must be positive")
+	}
+	for _, d := range rec.Weekdays {
+		if _, ok := weekdayCodes[d]; !ok {
+			return errors.New("unknown weekday " + d)
+		}
+	}
+	if rec.MonthDay < -1 || rec.MonthDay > 31 {
+		return errors.New("recurrence monthDay must be 1-31 or -1")
+	}
+	if rec.Count < 0 {
+		return errors.New("recurrence count must be positive")
+	}
+	if _, err := time.LoadLocation(rec.TZ); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Next returns the occurrence after prev, which is the due time of occurrence number n
+// (1-based). ok is false once the series has ended. Wall-clock time is preserved across
+// DST changes in the rule's zone.
+func (rec Recurrence) Next(prev time.Time, n int) (next time.Time, ok bool) {
+	if rec.Count > 0 && n >= rec.Count {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(rec.TZ)
+	if err != nil {
+		loc = time.UTC
+	}
+	prev = prev.In(loc)
+	interval := rec.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	switch rec.Freq {
+	case FreqDaily:
+		next = prev.AddDate(0, 0, interval)
+	case FreqWeekly:
+		next = rec.nextWeekly(prev, interval)
+	case FreqMonthly:
+		next = rec.nextMonthly(prev, interval)
+	default:
+		return time.Time{}, false
+	}
+
+	if rec.Until != nil && next.UnixMilli() > *rec.Until {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func (rec Recurrence) nextWeekly(prev time.Time, interval int) time.Time {
+	if len(rec.Weekdays) == 0 {
+		return prev.AddDate(0, 0, 7*interval)
+	}
+	want := map[time.Weekday]bool{}
+	for _, d := range rec.Weekdays {
+		want[weekdayCodes[d]] = true
+	}
+	// Weeks start on Monday (RRULE's default WKST); only every interval-th week counts.
+	base := weekStart(prev)
+	for i := 1; i <= 7*(interval+1); i++ {
+		d := prev.AddDate(0, 0, i)
+		weeks := int(weekStart(d).Sub(base).Hours()+12) / (24 * 7)
+		if weeks%interval == 0 && want[d.Weekday()] {
+			return d
+		}
+	}
+	return prev.AddDate(0, 0, 7*interval)
+}
+
+// nextMonthly clamps days that do not exist in the target month (e.g. the 31st in
+// April) to that month's last day rather than skipping the month.
+func (rec Recurrence) nextMonthly(prev time.Time, interval int) time.Time {
+	first := time.Date(prev.Year(), prev.Month()+time.Month(interval), 1, prev.Hour(), prev.Minute(), prev.Second(), 0, prev.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	day := rec.MonthDay
+	if day == 0 {
+		day = prev.Day()
+	}
+	if day == -1 || day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+func weekStart(t time.Time) time.Time {
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/pet/sim"
+)
+
+// Scoring turns the owner's todos into the pet's completion score and the mood that
+// score earns. The score is a completion ratio over todo events, each worth the todo's
+// effort weight and fading with the given half-life: creating a todo and letting it go
+// overdue count against the owner, completing it counts for them.
+type Scoring struct {
+	HalfLife time.Duration
+	GoldenAt float64 // a score at or above this makes the pet golden
+	GrumpyAt float64 // a score below this makes it grumpy
+}
+
+var DefaultScoring = Scoring{
+	HalfLife: 3 * 24 * time.Hour,
+	GoldenAt: 0.7,
+	GrumpyAt: 0.3,
+}
+
+// priorWeight is how many events' worth of a score halfway between the thresholds
+// every pet starts from, so a handful of todos cannot swing the mood to either end
+// and an owner who has done nothing lately drifts back to neutral.
+const priorWeight = 2
+
+// Scores are stored to this many decimal places.
+const scorePrecision = 100
+
+func (s Scoring) Validate() error {
+	if s.HalfLife <= 0 {
+		return errors.New("score half-life must be positive")
+	}
+	if s.GrumpyAt < 0 || s.GoldenAt > 1 || s.GrumpyAt >= s.GoldenAt {
+		return errors.New("score thresholds must satisfy 0 <= grumpy < golden <= 1")
+	}
+	return nil
+}
+
+// Score is the completion score for todos as of now, from 0 to 1. Trashed todos are
+// left out. Done todos without a completion time count as completed when last updated.
+func (s Scoring) Score(todos []Todo, now time.Time) float64 {
+	return s.ScoreOf(s.Sums(todos, now))
+}
+
+// ScoreSums is Score in running form, kept on the pet so that a todo write can move
+// the score by that todo's share without listing every todo. Completed and Expected
+// are the decayed weights of the events up to At. Pending holds the due times of open
+// todos still to come, which count against the owner once they pass.
+type ScoreSums struct {
+	At        int64        `dynamodbav:"at"` // unix ms
+	Completed float64      `dynamodbav:"completed"`
+	Expected  float64      `dynamodbav:"expected"`
+	Pending   []PendingDue `dynamodbav:"pending,omitempty"`
+	BuiltAt   int64        `dynamodbav:"builtAt"` // when Sums last rebuilt them, unix ms
+}
+
+type PendingDue struct {
+	TodoID string  `dynamodbav:"todoId"`
+	DueAt  int64   `dynamodbav:"dueAt"`
+	Weight float64 `dynamodbav:"weight"`
+}
+
+// Sums builds the running sums for todos as of now.
+func (s Scoring) Sums(todos []Todo, now time.Time) ScoreSums {
+	sums := ScoreSums{At: now.UnixMilli(), BuiltAt: now.UnixMilli()}
+	for i := range todos {
+		s.count(&sums, &todos[i], 1)
+	}
+	return sums
+}
+
+// Advance decays sums to now and counts the due times that have passed since.
+func (s Scoring) Advance(sums *ScoreSums, now time.Time) {
+	at := now.UnixMilli()
+	if at <= sums.At {
+		return
+	}
+	k := s.decay(sums.At, at)
+	sums.Completed *= k
+	sums.Expected *= k
+	sums.At = at
+
+	pending := sums.Pending[:0]
+	for _, d := range sums.Pending {
+		if d.DueAt <= at {
+			sums.Expected += d.Weight * s.decay(d.DueAt, at)
+		} else {
+			pending = append(pending, d)
+		}
+	}
+	sums.Pending = pending
+}
+
+// Update moves sums, advanced to now, from old's share to next's. Either may be nil.
+func (s Scoring) Update(sums *ScoreSums, old, next *Todo, now time.Time) {
+	s.Advance(sums, now)
+	s.count(sums, old, -1)
+	s.count(sums, next, 1)
+}
+
+// count adds t's events to sums, which are as of sums.At, or takes them away when
+// sign is -1.
+func (s Scoring) count(sums *ScoreSums, t *Todo, sign float64) {
+	if t == nil || t.Trashed() {
+		return
+	}
+	w := t.EffortWeight()
+	sums.Expected += sign * w * s.decay(t.CreatedAt, sums.At)
+
+	var doneAt *int64
+	if t.Done {
+		doneAt = &t.UpdatedAt
+		if t.CompletedAt != nil {
+			doneAt = t.CompletedAt
+		}
+		sums.Completed += sign * w * s.decay(*doneAt, sums.At)
+	}
+	if t.DueAt == nil || (doneAt != nil && *doneAt <= *t.DueAt) {
+		return
+	}
+	switch {
+	case *t.DueAt <= sums.At:
+		sums.Expected += sign * w * s.decay(*t.DueAt, sums.At)
+	case sign > 0:
+		sums.Pending = append(sums.Pending, PendingDue{TodoID: t.TodoID, DueAt: *t.DueAt, Weight: w})
+	default:
+		for i, d := range sums.Pending {
+			if d.TodoID == t.TodoID {
+				sums.Pending = append(sums.Pending[:i], sums.Pending[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// decay is how much an event at from still weighs at to.
+func (s Scoring) decay(from, to int64) float64 {
+	elapsed := max(to-from, 0)
+	return math.Exp2(-float64(elapsed) / float64(s.HalfLife.Milliseconds()))
+}
+
+// ScoreOf is the completion score sums add up to, from 0 to 1.
+func (s Scoring) ScoreOf(sums ScoreSums) float64 {
+	prior := (s.GoldenAt + s.GrumpyAt) / 2
+	completed, expected := max(sums.Completed, 0), max(sums.Expected, 0)
+	score := (completed + prior*priorWeight) / (expected + priorWeight)
+	return min(max(score, 0), 1)
+}
+
+// The moods the server reports. The API has always called the unhappy one grumpy;
+// the desktop pet, and so the shared simulation, call it sad.
+const (
+	MoodGrumpy  = "grumpy"
+	MoodNeutral = string(sim.MoodNeutral)
+	MoodGolden  = string(sim.MoodGolden)
+)
+
+// Mood is the mood a completion score earns.
+func (s Scoring) Mood(score float64) string {
+	switch {
+	case score >= s.GoldenAt:
+		return MoodGolden
+	case score < s.GrumpyAt:
+		return MoodGrumpy
+	default:
+		return MoodNeutral
+	}
+}
+
+// Rescore records score as the pet's completion score and updates its mood. It
+// reports whether the stored score or the mood it earns changed.
+func (p *PetState) Rescore(s Scoring, score float64) bool {
+	score = math.Round(score*scorePrecision) / scorePrecision
+	work := s.Mood(score)
+	changed := score != p.CompletionScore || work != p.WorkMood
+	p.CompletionScore, p.WorkMood = score, work
+	p.updateMood()
+	return changed
+}
+
+// updateMood sets Mood from the mood the owner's work earns, one step worse when the
+// pet is hungry or tired: a golden retriever still needs feeding.
+func (p *PetState) updateMood() {
+	mood := p.WorkMood
+	switch mood {
+	case "":
+		mood = MoodNeutral
+	case string(sim.MoodGrumpy): // stored by servers that reported the sim's name
+		mood = MoodGrumpy
+	}
+	if p.Stats.Mood() == sim.MoodGrumpy {
+		switch mood {
+		case MoodGolden:
+			mood = MoodNeutral
+		case MoodNeutral:
+			mood = MoodGrumpy
+		}
+	}
+	p.Mood = mood
+}
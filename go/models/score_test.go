@@ -0,0 +1,90 @@
+package models
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestScoreSumsUpdate(t *testing.T) {
+	s := DefaultScoring
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	ms := func(d time.Duration) int64 { return start.Add(d).UnixMilli() }
+	ptr := func(v int64) *int64 { return &v }
+
+	todos := map[string]Todo{}
+	sums := s.Sums(nil, start)
+	write := func(at time.Duration, next Todo) {
+		var old *Todo
+		if prev, ok := todos[next.TodoID]; ok {
+			old = &prev
+		}
+		next.UpdatedAt = ms(at)
+		s.Update(&sums, old, &next, start.Add(at))
+		todos[next.TodoID] = next
+	}
+	check := func(at time.Duration) {
+		t.Helper()
+		now := start.Add(at)
+		list := make([]Todo, 0, len(todos))
+		for _, todo := range todos {
+			list = append(list, todo)
+		}
+		s.Advance(&sums, now)
+		if got, want := s.ScoreOf(sums), s.Score(list, now); math.Abs(got-want) > 1e-9 {
+			t.Errorf("at %v running score %v, want %v", at, got, want)
+		}
+	}
+
+	write(0, Todo{TodoID: "a", CreatedAt: ms(0), DueAt: ptr(ms(2 * time.Hour))})
+	write(time.Hour, Todo{TodoID: "b", CreatedAt: ms(time.Hour), DueAt: ptr(ms(30 * time.Hour)), EstimateMinutes: 120})
+	write(time.Hour, Todo{TodoID: "c", CreatedAt: ms(time.Hour)})
+	check(90 * time.Minute)
+
+	// a goes overdue while nothing is written.
+	check(3 * time.Hour)
+
+	// a is done late, b early, and c is done and then reopened.
+	write(4*time.Hour, Todo{TodoID: "a", CreatedAt: ms(0), DueAt: ptr(ms(2 * time.Hour)), Done: true, CompletedAt: ptr(ms(4 * time.Hour))})
+	write(5*time.Hour, Todo{TodoID: "b", CreatedAt: ms(time.Hour), DueAt: ptr(ms(30 * time.Hour)), EstimateMinutes: 120, Done: true, CompletedAt: ptr(ms(5 * time.Hour))})
+	write(6*time.Hour, Todo{TodoID: "c", CreatedAt: ms(time.Hour), Done: true, CompletedAt: ptr(ms(6 * time.Hour))})
+	write(7*time.Hour, Todo{TodoID: "c", CreatedAt: ms(time.Hour)})
+	check(8 * time.Hour)
+
+	// c is given a due date, which is then moved, and passes.
+	write(9*time.Hour, Todo{TodoID: "c", CreatedAt: ms(time.Hour), DueAt: ptr(ms(10 * time.Hour))})
+	write(9*time.Hour, Todo{TodoID: "c", CreatedAt: ms(time.Hour), DueAt: ptr(ms(20 * time.Hour))})
+	check(12 * time.Hour)
+	check(40 * time.Hour)
+
+	// Trashing a takes its events back out.
+	write(41*time.Hour, Todo{TodoID: "a", CreatedAt: ms(0), DueAt: ptr(ms(2 * time.Hour)), Done: true, CompletedAt: ptr(ms(4 * time.Hour)), DeletedAt: ptr(ms(41 * time.Hour))})
+	check(48 * time.Hour)
+	if len(sums.Pending) != 0 {
+		t.Errorf("pending %+v after every due time passed", sums.Pending)
+	}
+}
+
+func TestMood(t *testing.T) {
+	s := DefaultScoring
+	for _, tc := range []struct {
+		score float64
+		want  string
+	}{
+		{0, MoodGrumpy},
+		{0.29, MoodGrumpy},
+		{0.3, MoodNeutral},
+		{0.69, MoodNeutral},
+		{0.7, MoodGolden},
+	} {
+		if got := s.Mood(tc.score); got != tc.want {
+			t.Errorf("Mood(%v) = %q, want %q", tc.score, got, tc.want)
+		}
+	}
+
+	p := PetState{WorkMood: "sad"}
+	p.updateMood()
+	if p.Mood != MoodGrumpy {
+		t.Errorf("stored sad mood reads as %q, want %q", p.Mood, MoodGrumpy)
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+// TimeSession is a stretch of time tracked against a todo. EndedAt is nil while the
+// timer is still running.
+type TimeSession struct {
+	UserID     string `dynamodbav:"userId" json:"userId"`
+	SessionKey string `dynamodbav:"sessionKey" json:"-"`
+	TodoID     string `dynamodbav:"todoId" json:"todoId"`
+	OwnerID    string `dynamodbav:"ownerId,omitempty" json:"ownerId,omitempty"` // set when the todo was shared with UserID
+	StartedAt  int64  `dynamodbav:"startedAt" json:"startedAt"`
+	EndedAt    *int64 `dynamodbav:"endedAt,omitempty" json:"endedAt,omitempty"`
+}
+
+// Running reports whether the session's timer has not been stopped yet.
+func (s TimeSession) Running() bool {
+	return s.EndedAt == nil
+}
+
+// DurationMs is the session's length, counting a running timer up to now (unix ms).
+func (s TimeSession) DurationMs(now int64) int64 {
+	end := now
+	if s.EndedAt != nil {
+		end = *s.EndedAt
+	}
+	return max(end-s.StartedAt, 0)
+}
+
+// DailyTime is the tracked time that fell on one calendar day.
+type DailyTime struct {
+	Date    string           `json:"date"` // YYYY-MM-DD in the requested time zone
+	TotalMs int64            `json:"totalMs"`
+	Todos   map[string]int64 `json:"todos"` // todoId -> ms
+}
@@ -0,0 +1,41 @@
+package models
+
+// Roles a user can hold on a todo. Owners can do anything, editors can change the
+// todo but not share it, and viewers can only read it.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// What a share grants access to.
+const (
+	ShareTodo    = "todo"
+	ShareProject = "project" // every todo filed under the project
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// ValidShareRole reports whether role can be granted through a share.
+func ValidShareRole(role string) bool {
+	return role == RoleViewer || role == RoleEditor
+}
+
+// RoleAllows reports whether holding role is enough for an action that needs need.
+func RoleAllows(role, need string) bool {
+	return roleRank[role] >= roleRank[need]
+}
+
+// Share grants UserID a role on another user's todo or project. Rows are keyed by the
+// recipient; ItemKey lets the owner look up everyone an item is shared with.
+type Share struct {
+	UserID    string `dynamodbav:"userId" json:"userId"`
+	ShareKey  string `dynamodbav:"shareKey" json:"-"` // "<kind>#<ownerId>#<itemId>"
+	OwnerID   string `dynamodbav:"ownerId" json:"ownerId"`
+	ItemKey   string `dynamodbav:"itemKey" json:"-"` // "<kind>#<itemId>#<userId>"
+	Kind      string `dynamodbav:"kind" json:"kind"` // todo | project
+	ItemID    string `dynamodbav:"itemId" json:"itemId"`
+	Role      string `dynamodbav:"role" json:"role"`
+	Email     string `dynamodbav:"email" json:"email"`
+	CreatedAt int64  `dynamodbav:"createdAt" json:"createdAt"`
+}
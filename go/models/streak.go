@@ -0,0 +1,123 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// DefaultStreakGoal is how many todos a day takes to keep a streak going for pets
+// whose owner has not picked a goal.
+const DefaultStreakGoal = 1
+
+const maxStreakGoal = 20
+
+func ValidateStreakGoal(goal int) error {
+	if goal < 1 || goal > maxStreakGoal {
+		return errors.New("streakGoal must be between 1 and 20")
+	}
+	return nil
+}
+
+// Location is the owner's time zone, which decides where their days begin and end.
+func (p *PetState) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (p *PetState) Goal() int {
+	if p.StreakGoal <= 0 {
+		return DefaultStreakGoal
+	}
+	return p.StreakGoal
+}
+
+// Visit records that the owner looked at the pet at now, from time zone tz if given.
+// It reports whether anything changed, so callers only write when it did.
+func (p *PetState) Visit(now time.Time, tz string) bool {
+	changed := false
+	if tz != "" && tz != p.Timezone {
+		p.Timezone = tz
+		changed = true
+	}
+	if day := now.In(p.Location()).Format(time.DateOnly); day != p.LastLoginDay {
+		p.LastLoginDay = day
+		changed = true
+	}
+	return changed
+}
+
+// RecordCompletion counts a todo completed at now toward the owner's current day.
+// Days are calendar days wherever the owner is at the time, and one counts toward the
+// streak once it reaches the goal; it stays counted even if todos are reopened later.
+func (p *PetState) RecordCompletion(now time.Time) {
+	loc := p.Location()
+	day := now.In(loc).Format(time.DateOnly)
+	// Flying west over the date line can make today's date one that already passed;
+	// keep counting toward the day in progress rather than reopening an old one.
+	if day < p.StreakDay {
+		day = p.StreakDay
+	}
+	if day != p.StreakDay {
+		p.StreakDay, p.StreakDayCount = day, 0
+	}
+	p.StreakDayCount++
+
+	if p.StreakDayCount < p.Goal() || p.LastStreakDay == day {
+		return
+	}
+	if p.LastStreakDay != "" && streakContinues(p.LastStreakDayEnd, day, loc) {
+		p.StreakDays++
+	} else {
+		p.StreakDays = 1
+	}
+	p.LastStreakDay = day
+	p.LastStreakDayEnd = dayEnd(day, loc).UnixMilli()
+	p.BestStreakDays = max(p.BestStreakDays, p.StreakDays)
+}
+
+// UndoCompletion takes back a completion made at completedAt when its todo is
+// reopened, if it still counts toward the day in progress.
+func (p *PetState) UndoCompletion(completedAt time.Time) {
+	day := completedAt.In(p.Location()).Format(time.DateOnly)
+	if day == p.StreakDay && p.StreakDayCount > 0 {
+		p.StreakDayCount--
+	}
+}
+
+// CurrentStreak is the streak as of now: the stored streak while its last day is today
+// or the day before, and zero once a whole day has gone by without reaching the goal.
+func (p *PetState) CurrentStreak(now time.Time) int64 {
+	if p.LastStreakDay == "" {
+		return 0
+	}
+	loc := p.Location()
+	today := now.In(loc).Format(time.DateOnly)
+	if today <= p.LastStreakDay || streakContinues(p.LastStreakDayEnd, today, loc) {
+		return p.StreakDays
+	}
+	return 0
+}
+
+// streakContinues reports whether day, a date in loc, follows on from a streak day
+// that ended at lastEnd (unix ms) without a whole day in between. It compares dates
+// rather than durations, so a 23 or 25 hour day around a DST change is still one day.
+// After a change of time zone lastEnd is read in the new zone: flying east over the
+// date line skips a date without breaking the streak, and any day that starts after
+// the previous one ended follows on from it.
+func streakContinues(lastEnd int64, day string, loc *time.Location) bool {
+	end := time.UnixMilli(lastEnd).In(loc)
+	next := time.Date(end.Year(), end.Month(), end.Day(), 0, 0, 0, 0, loc)
+	if next.Before(end) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return day <= next.Format(time.DateOnly)
+}
+
+// dayEnd returns midnight at the end of day, a YYYY-MM-DD date, in loc.
+func dayEnd(day string, loc *time.Location) time.Time {
+	d, _ := time.ParseInLocation(time.DateOnly, day, loc)
+	return time.Date(d.Year(), d.Month(), d.Day()+1, 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStreak(t *testing.T) {
+	// A step completes a todo at a local time in tz, or with peek only looks at the
+	// pet then, and expects CurrentStreak to be want afterwards.
+	type step struct {
+		tz   string
+		at   string // "YYYY-MM-DD hh:mm" in tz
+		peek bool
+		want int64
+	}
+
+	for _, tc := range []struct {
+		name  string
+		goal  int
+		steps []step
+	}{
+		{
+			name: "spring forward",
+			steps: []step{
+				{tz: "America/New_York", at: "2026-03-07 23:30", want: 1},
+				{tz: "America/New_York", at: "2026-03-08 23:30", want: 2}, // a 23 hour day
+				{tz: "America/New_York", at: "2026-03-09 00:30", want: 3},
+				{tz: "America/New_York", at: "2026-03-10 23:59", peek: true, want: 3},
+			},
+		},
+		{
+			name: "fall back",
+			steps: []step{
+				{tz: "America/New_York", at: "2026-10-31 00:10", want: 1},
+				{tz: "America/New_York", at: "2026-11-01 23:50", want: 2}, // a 25 hour day
+				{tz: "America/New_York", at: "2026-11-02 00:05", want: 3},
+				{tz: "America/New_York", at: "2026-11-04 00:00", peek: true, want: 0},
+			},
+		},
+		{
+			name: "moving east mid-streak",
+			steps: []step{
+				{tz: "America/New_York", at: "2026-05-01 22:00", want: 1},
+				{tz: "Asia/Tokyo", at: "2026-05-03 09:00", want: 2},
+				{tz: "Asia/Tokyo", at: "2026-05-04 09:00", want: 3},
+			},
+		},
+		{
+			name: "moving west mid-streak",
+			steps: []step{
+				{tz: "Asia/Tokyo", at: "2026-05-01 08:00", want: 1},
+				{tz: "America/Los_Angeles", at: "2026-05-01 20:00", want: 1}, // the same date again
+				{tz: "America/Los_Angeles", at: "2026-05-02 20:00", want: 2},
+			},
+		},
+		{
+			name: "over the date line, east",
+			steps: []step{
+				{tz: "Pacific/Honolulu", at: "2026-05-01 20:00", want: 1},
+				{tz: "Pacific/Auckland", at: "2026-05-03 20:00", want: 2}, // May 2 never happened here
+			},
+		},
+		{
+			name: "over the date line, west",
+			steps: []step{
+				{tz: "Pacific/Auckland", at: "2026-05-02 09:00", want: 1},
+				{tz: "Pacific/Honolulu", at: "2026-05-01 12:00", want: 1}, // still the day in progress
+				{tz: "Pacific/Honolulu", at: "2026-05-02 12:00", want: 1},
+				{tz: "Pacific/Honolulu", at: "2026-05-03 12:00", want: 2},
+			},
+		},
+		{
+			name: "resets after a missed day",
+			steps: []step{
+				{tz: "Europe/Berlin", at: "2026-06-01 09:00", want: 1},
+				{tz: "Europe/Berlin", at: "2026-06-02 23:59", want: 2},
+				{tz: "Europe/Berlin", at: "2026-06-03 23:59", peek: true, want: 2},
+				{tz: "Europe/Berlin", at: "2026-06-04 00:00", peek: true, want: 0},
+				{tz: "Europe/Berlin", at: "2026-06-04 00:01", want: 1},
+			},
+		},
+		{
+			name: "counts a day only at the goal",
+			goal: 2,
+			steps: []step{
+				{tz: "UTC", at: "2026-06-01 09:00", want: 0},
+				{tz: "UTC", at: "2026-06-01 10:00", want: 1},
+				{tz: "UTC", at: "2026-06-01 11:00", want: 1},
+				{tz: "UTC", at: "2026-06-02 09:00", want: 1},
+				{tz: "UTC", at: "2026-06-03 09:00", want: 0},
+				{tz: "UTC", at: "2026-06-03 10:00", want: 1},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := PetState{StreakGoal: tc.goal}
+			for i, st := range tc.steps {
+				loc, err := time.LoadLocation(st.tz)
+				if err != nil {
+					t.Fatal(err)
+				}
+				at, err := time.ParseInLocation("2006-01-02 15:04", st.at, loc)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				p.Visit(at, st.tz)
+				if !st.peek {
+					p.RecordCompletion(at)
+				}
+				if got := p.CurrentStreak(at); got != st.want {
+					t.Errorf("step %d at %s %s: streak %d, want %d", i, st.at, st.tz, got, st.want)
+				}
+			}
+		})
+	}
+}
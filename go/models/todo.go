@@ -1,12 +1,138 @@
 package models
 
+import (
+	"errors"
+	"strings"
+)
+
+const (
+	PriorityLow    = "low"
+	PriorityMedium = "medium"
+	PriorityHigh   = "high"
+)
+
+const maxTags = 20
+
+func ValidPriority(p string) bool {
+	switch p {
+	case "", PriorityLow, PriorityMedium, PriorityHigh:
+		return true
+	}
+	return false
+}
+
+// NormalizeTags lowercases and trims tags, drops a leading '#', and removes duplicates
+// while keeping the caller's order.
+func NormalizeTags(tags []string) ([]string, error) {
+	out := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if strings.ContainsAny(tag, "# \t") {
+			return nil, errors.New("tags cannot contain spaces or '#'")
+		}
+		seen[tag] = true
+		out = append(out, tag)
+	}
+	if len(out) > maxTags {
+		return nil, errors.New("too many tags")
+	}
+	return out, nil
+}
+
 type Todo struct {
-	UserID          string `dynamodbav:"userId"`
-	TodoID          string `dynamodbav:"todoId"`
-	Text            string `dynamodbav:"text"`
-	Done            bool   `dynamodbav:"done"`
-	CreatedAt       int64  `dynamodbav:"createdAt"`
-	UpdatedAt       int64  `dynamodbav:"updatedAt"`
-	DueAt           *int64 `dynamodbav:"dueAt,omitempty"`
-	CalendarEventID string `dynamodbav:"calendarEventId,omitempty"`
+	UserID          string `dynamodbav:"userId" json:"userId"`
+	TodoID          string `dynamodbav:"todoId" json:"todoId"`
+	Text            string `dynamodbav:"text" json:"text"`
+	Notes           string `dynamodbav:"notes,omitempty" json:"notes,omitempty"`
+	Done            bool   `dynamodbav:"done" json:"done"`
+	CreatedAt       int64  `dynamodbav:"createdAt" json:"createdAt"`
+	UpdatedAt       int64  `dynamodbav:"updatedAt" json:"updatedAt"`
+	Version         int64  `dynamodbav:"version" json:"version"` // bumped on every write; exposed as the ETag
+	DueAt           *int64 `dynamodbav:"dueAt,omitempty" json:"dueAt,omitempty"`
+	CalendarEventID string `dynamodbav:"calendarEventId,omitempty" json:"calendarEventId,omitempty"`
+	CalendarVersion int64  `dynamodbav:"calendarVersion,omitempty" json:"-"`             // Version last synced to the event
+	ProjectID       string `dynamodbav:"projectId,omitempty" json:"projectId,omitempty"` // empty for the inbox
+
+	// Role is set, but never stored, when the todo is seen by someone it was shared with.
+	Role string `dynamodbav:"-" json:"role,omitempty"`
+
+	// Rank is a fractional index giving the user's manual order; RankKey mirrors it
+	// with the todoId appended so the rank index breaks ties deterministically.
+	Rank    string `dynamodbav:"rank,omitempty" json:"rank,omitempty"`
+	RankKey string `dynamodbav:"rankKey,omitempty" json:"-"`
+
+	// DeletedAt marks a todo as in the trash; ExpiresAt (unix seconds) is the table's
+	// TTL attribute, after which DynamoDB purges the item.
+	DeletedAt *int64 `dynamodbav:"deletedAt,omitempty" json:"deletedAt,omitempty"`
+	ExpiresAt *int64 `dynamodbav:"expiresAt,omitempty" json:"-"`
+
+	// TrackedMs totals the todo's finished time-tracking sessions, to compare with
+	// EstimateMinutes, the user's guess at the effort. CompletedAt is when the todo
+	// was last marked done.
+	TrackedMs       int64  `dynamodbav:"trackedMs,omitempty" json:"trackedMs,omitempty"`
+	EstimateMinutes int    `dynamodbav:"estimateMinutes,omitempty" json:"estimateMinutes,omitempty"`
+	CompletedAt     *int64 `dynamodbav:"completedAt,omitempty" json:"completedAt,omitempty"`
+
+	Priority string   `dynamodbav:"priority,omitempty" json:"priority,omitempty"` // low | medium | high
+	Tags     []string `dynamodbav:"tags,omitempty" json:"tags,omitempty"`
+
+	// Subtasks is an ordered checklist. With AutoComplete set, Done follows the checklist.
+	Subtasks     []Subtask `dynamodbav:"subtasks,omitempty" json:"subtasks,omitempty"`
+	AutoComplete bool      `dynamodbav:"autoComplete,omitempty" json:"autoComplete,omitempty"`
+
+	// Recurring todos are materialised one occurrence at a time. SeriesID is the TodoID
+	// of the first occurrence and Occurrence is this instance's 1-based position.
+	Recurrence *Recurrence `dynamodbav:"recurrence,omitempty" json:"recurrence,omitempty"`
+	SeriesID   string      `dynamodbav:"seriesId,omitempty" json:"seriesId,omitempty"`
+	Occurrence int         `dynamodbav:"occurrence,omitempty" json:"occurrence,omitempty"`
+}
+
+type Subtask struct {
+	SubtaskID string `dynamodbav:"subtaskId" json:"subtaskId"`
+	Text      string `dynamodbav:"text" json:"text"`
+	Done      bool   `dynamodbav:"done" json:"done"`
+}
+
+// Progress reports completion in [0, 1]; an open todo earns partial credit per finished subtask.
+func (t Todo) Progress() float64 {
+	if t.Done {
+		return 1
+	}
+	if len(t.Subtasks) == 0 {
+		return 0
+	}
+	done := 0
+	for _, s := range t.Subtasks {
+		if s.Done {
+			done++
+		}
+	}
+	return float64(done) / float64(len(t.Subtasks))
+}
+
+func (t Todo) Trashed() bool {
+	return t.DeletedAt != nil
+}
+
+func (t *Todo) SetRank(rank string) {
+	t.Rank = rank
+	t.RankKey = rank + "#" + t.TodoID
+}
+
+// SyncAutoComplete sets Done from the checklist when AutoComplete is enabled.
+func (t *Todo) SyncAutoComplete() {
+	if !t.AutoComplete || len(t.Subtasks) == 0 {
+		return
+	}
+	for _, s := range t.Subtasks {
+		if !s.Done {
+			t.Done = false
+			return
+		}
+	}
+	t.Done = true
 }
@@ -1,5 +1,19 @@
 package models
 
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// DefaultReminderLead is used for users who have not chosen their own lead times.
+const DefaultReminderLead = time.Hour
+
+// MaxReminderLead bounds how far ahead of a due time a reminder may fire.
+const MaxReminderLead = 7 * 24 * time.Hour
+
+const maxReminderLeads = 5
+
 type User struct {
 	UserID    string `dynamodbav:"userId" json:"userId"`
 	Email     string `dynamodbav:"email" json:"email"`
@@ -7,4 +21,49 @@ type User struct {
 	Picture   string `dynamodbav:"picture" json:"picture"`
 	CreatedAt int64  `dynamodbav:"createdAt" json:"createdAt"`
 	UpdatedAt int64  `dynamodbav:"updatedAt" json:"updatedAt"`
+
+	// ReminderLeadMinutes lists how long before a todo is due to remind the user.
+	ReminderLeadMinutes []int `dynamodbav:"reminderLeadMinutes,omitempty" json:"reminderLeadMinutes,omitempty"`
+
+	// FeedToken is the secret in the user's calendar feed URL. It is never returned
+	// with the user; the feed endpoints hand it out.
+	FeedToken string `dynamodbav:"feedToken,omitempty" json:"-"`
+
+	// GoogleRefreshToken lets calendar sync act for the user while they are away, and
+	// CalendarSyncToken is where its last pass over their calendar left off.
+	GoogleRefreshToken string `dynamodbav:"googleRefreshToken,omitempty" json:"-"`
+	CalendarSyncToken  string `dynamodbav:"calendarSyncToken,omitempty" json:"-"`
+}
+
+// ReminderLeads returns the user's lead times, or the default if none are set.
+func (u *User) ReminderLeads() []time.Duration {
+	if u == nil || len(u.ReminderLeadMinutes) == 0 {
+		return []time.Duration{DefaultReminderLead}
+	}
+	leads := make([]time.Duration, len(u.ReminderLeadMinutes))
+	for i, m := range u.ReminderLeadMinutes {
+		leads[i] = time.Duration(m) * time.Minute
+	}
+	return leads
+}
+
+// NormalizeReminderLeads validates lead times in minutes, dropping duplicates and
+// sorting them shortest first.
+func NormalizeReminderLeads(minutes []int) ([]int, error) {
+	seen := map[int]bool{}
+	out := make([]int, 0, len(minutes))
+	for _, m := range minutes {
+		if m <= 0 || time.Duration(m)*time.Minute > MaxReminderLead {
+			return nil, errors.New("reminder lead times must be between 1 minute and 7 days")
+		}
+		if !seen[m] {
+			seen[m] = true
+			out = append(out, m)
+		}
+	}
+	if len(out) > maxReminderLeads {
+		return nil, errors.New("too many reminder lead times")
+	}
+	sort.Ints(out)
+	return out, nil
 }
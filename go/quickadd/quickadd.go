@@ -0,0 +1,524 @@
+// Package quickadd turns a one-line todo such as
+//
+//	pay rent tomorrow 5pm #home !high every month
+//
+// into its text, due time, tags, priority and recurrence. Dates and times are read in
+// the location of the reference time passed to Parse. A word starting with a backslash
+// is kept as text without it, so `\#1` and `\tomorrow` are never parsed.
+package quickadd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// Due dates given without a time of day default to the end of that day.
+const (
+	defaultHour   = 23
+	defaultMinute = 59
+	tonightHour   = 20
+)
+
+type Result struct {
+	Text       string             `json:"text"`
+	DueAt      *int64             `json:"dueAt,omitempty"`
+	Tags       []string           `json:"tags,omitempty"`
+	Priority   string             `json:"priority,omitempty"`
+	Recurrence *models.Recurrence `json:"recurrence,omitempty"`
+}
+
+var (
+	priorities = map[string]string{
+		"high": models.PriorityHigh, "h": models.PriorityHigh, "1": models.PriorityHigh, "!": models.PriorityHigh,
+		"medium": models.PriorityMedium, "med": models.PriorityMedium, "m": models.PriorityMedium, "2": models.PriorityMedium,
+		"low": models.PriorityLow, "l": models.PriorityLow, "3": models.PriorityLow,
+	}
+	weekdays = map[string]time.Weekday{
+		"sunday": time.Sunday, "sun": time.Sunday,
+		"monday": time.Monday, "mon": time.Monday,
+		"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+		"wednesday": time.Wednesday, "wed": time.Wednesday,
+		"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+		"friday": time.Friday, "fri": time.Friday,
+		"saturday": time.Saturday, "sat": time.Saturday,
+	}
+	connectors   = map[string]bool{"at": true, "on": true, "by": true, "due": true}
+	weekdayCodes = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+	months       = map[string]time.Month{
+		"jan": time.January, "january": time.January, "feb": time.February, "february": time.February,
+		"mar": time.March, "march": time.March, "apr": time.April, "april": time.April,
+		"may": time.May, "jun": time.June, "june": time.June, "jul": time.July, "july": time.July,
+		"aug": time.August, "august": time.August, "sep": time.September, "sept": time.September,
+		"september": time.September, "oct": time.October, "october": time.October,
+		"nov": time.November, "november": time.November, "dec": time.December, "december": time.December,
+	}
+
+	clockRe   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)$`)
+	clock24Re = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+	isoDateRe = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	slashRe   = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})(?:/(\d{4}))?$`)
+	ordinalRe = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+)
+
+// date is a calendar day without a time; zero means unset.
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+type clock struct {
+	hour, minute int
+	set          bool
+}
+
+type parser struct {
+	words []string // original words
+	lower []string // lowercased, trailing commas stripped
+	used  []bool
+	now   time.Time
+
+	day     date
+	at      clock
+	exact   *time.Time // "in 2 hours" fixes both date and time
+	tonight bool
+	result  Result
+}
+
+// Parse extracts structured fields from input relative to now. Words it does not
+// recognise are kept, in order, as the todo text.
+func Parse(input string, now time.Time) Result {
+	words := strings.Fields(input)
+	p := &parser{words: words, lower: make([]string, len(words)), used: make([]bool, len(words)), now: now}
+	for i, w := range words {
+		p.lower[i] = strings.TrimRight(strings.ToLower(w), ",.")
+		if strings.HasPrefix(w, `\`) && len(w) > 1 {
+			words[i], p.lower[i] = w[1:], ""
+		}
+	}
+
+	for i := 0; i < len(words); i++ {
+		if p.used[i] {
+			continue
+		}
+		w := p.lower[i]
+		switch {
+		case w == "":
+			// Escaped, or only punctuation: plain text.
+		case strings.HasPrefix(w, "#") && len(w) > 1:
+			p.result.Tags = append(p.result.Tags, strings.TrimPrefix(w, "#"))
+			p.used[i] = true
+		case strings.HasPrefix(w, "!") && priorities[w[1:]] != "":
+			p.result.Priority = priorities[w[1:]]
+			p.used[i] = true
+		default:
+			if n := p.recurrence(i); n > 0 {
+				p.consume(i, n)
+			} else if n := p.when(i); n > 0 {
+				p.consume(i, n)
+			} else if connectors[w] && i+1 < len(words) {
+				// "at 5pm", "on friday": drop the connector only if what follows parses.
+				if n := p.when(i + 1); n > 0 {
+					p.consume(i, n+1)
+				}
+			}
+		}
+	}
+
+	var text []string
+	for i, w := range words {
+		if !p.used[i] {
+			text = append(text, w)
+		}
+	}
+	p.result.Text = strings.Join(text, " ")
+	p.result.DueAt = p.due()
+	if p.result.Recurrence != nil {
+		p.result.Recurrence.TZ = now.Location().String()
+		if p.result.Recurrence.TZ == "Local" {
+			p.result.Recurrence.TZ = ""
+		}
+	}
+	return p.result
+}
+
+func (p *parser) consume(i, n int) {
+	for j := i; j < i+n && j < len(p.used); j++ {
+		p.used[j] = true
+	}
+}
+
+func (p *parser) word(i int) string {
+	if i < len(p.lower) && !p.used[i] {
+		return p.lower[i]
+	}
+	return ""
+}
+
+// when parses a date, a time, or a relative offset starting at word i and returns
+// the number of words it used.
+func (p *parser) when(i int) int {
+	if n := p.relative(i); n > 0 {
+		return n
+	}
+	if h, m, n := p.clockAt(i); n > 0 {
+		if p.at.set {
+			return 0
+		}
+		p.at = clock{hour: h, minute: m, set: true}
+		return n
+	}
+	if d, n := p.dateAt(i); n > 0 {
+		if p.day != (date{}) {
+			return 0
+		}
+		p.day = d
+		return n
+	}
+	return 0
+}
+
+// relative handles "in N minutes|hours|days|weeks".
+func (p *parser) relative(i int) int {
+	if p.word(i) != "in" || p.exact != nil {
+		return 0
+	}
+	n, err := strconv.Atoi(p.word(i + 1))
+	if err != nil || n <= 0 {
+		return 0
+	}
+	unit := strings.TrimSuffix(p.word(i+2), "s")
+	var t time.Time
+	switch unit {
+	case "min", "minute":
+		t = p.now.Add(time.Duration(n) * time.Minute)
+	case "hour", "hr":
+		t = p.now.Add(time.Duration(n) * time.Hour)
+	case "day":
+		p.day = dateOf(p.now.AddDate(0, 0, n))
+		return 3
+	case "week":
+		p.day = dateOf(p.now.AddDate(0, 0, 7*n))
+		return 3
+	default:
+		return 0
+	}
+	p.exact = &t
+	return 3
+}
+
+func (p *parser) clockAt(i int) (hour, minute, n int) {
+	w := p.word(i)
+	if w == "noon" {
+		return 12, 0, 1
+	}
+	// "5 pm" written as two words.
+	if next := p.word(i + 1); (next == "am" || next == "pm") && w != "" {
+		w += next
+		n = 1
+	}
+	if m := clockRe.FindStringSubmatch(w); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			minute, _ = strconv.Atoi(m[2])
+		}
+		if hour < 1 || hour > 12 || minute > 59 {
+			return 0, 0, 0
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+		return hour, minute, n + 1
+	}
+	if m := clock24Re.FindStringSubmatch(w); m != nil && n == 0 {
+		hour, _ = strconv.Atoi(m[1])
+		minute, _ = strconv.Atoi(m[2])
+		if hour > 23 || minute > 59 {
+			return 0, 0, 0
+		}
+		return hour, minute, 1
+	}
+	return 0, 0, 0
+}
+
+func (p *parser) dateAt(i int) (date, int) {
+	w := p.word(i)
+	today := dateOf(p.now)
+	switch w {
+	case "today":
+		return today, 1
+	case "tonight":
+		p.tonight = true
+		return today, 1
+	case "tomorrow", "tmr", "tmrw":
+		return dateOf(p.now.AddDate(0, 0, 1)), 1
+	case "next":
+		switch next := p.word(i + 1); next {
+		case "week":
+			return dateOf(p.nextWeekday(time.Monday)), 2
+		case "month":
+			return date{p.now.Year(), p.now.Month() + 1, 1}.normalize(p.now.Location()), 2
+		default:
+			if wd, ok := weekdays[next]; ok {
+				return dateOf(p.nextWeekday(wd)), 2
+			}
+		}
+		return date{}, 0
+	}
+	if wd, ok := weekdays[w]; ok {
+		return dateOf(p.nextWeekday(wd)), 1
+	}
+	if m := isoDateRe.FindStringSubmatch(w); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		mo, _ := strconv.Atoi(m[2])
+		d, _ := strconv.Atoi(m[3])
+		return p.validDate(y, time.Month(mo), d, 1)
+	}
+	if m := slashRe.FindStringSubmatch(w); m != nil {
+		mo, _ := strconv.Atoi(m[1])
+		d, _ := strconv.Atoi(m[2])
+		if m[3] != "" {
+			y, _ := strconv.Atoi(m[3])
+			return p.validDate(y, time.Month(mo), d, 1)
+		}
+		return p.upcoming(time.Month(mo), d, 1)
+	}
+	// "nov 20" and "20 nov"
+	if mo, ok := months[w]; ok {
+		if m := ordinalRe.FindStringSubmatch(p.word(i + 1)); m != nil {
+			d, _ := strconv.Atoi(m[1])
+			return p.upcoming(mo, d, 2)
+		}
+	}
+	if m := ordinalRe.FindStringSubmatch(w); m != nil {
+		if mo, ok := months[p.word(i+1)]; ok {
+			d, _ := strconv.Atoi(m[1])
+			return p.upcoming(mo, d, 2)
+		}
+	}
+	return date{}, 0
+}
+
+// upcoming resolves a month and day without a year to its next occurrence.
+func (p *parser) upcoming(mo time.Month, d, n int) (date, int) {
+	y := p.now.Year()
+	if mo < p.now.Month() || (mo == p.now.Month() && d < p.now.Day()) {
+		y++
+	}
+	return p.validDate(y, mo, d, n)
+}
+
+func (p *parser) validDate(y int, mo time.Month, d, n int) (date, int) {
+	if mo < time.January || mo > time.December || d < 1 {
+		return date{}, 0
+	}
+	t := time.Date(y, mo, d, 0, 0, 0, 0, p.now.Location())
+	if t.Day() != d {
+		return date{}, 0
+	}
+	return date{y, mo, d}, n
+}
+
+// nextWeekday is the next given weekday strictly after today.
+func (p *parser) nextWeekday(wd time.Weekday) time.Time {
+	days := (int(wd) - int(p.now.Weekday()) + 7) % 7
+	if days == 0 {
+		days = 7
+	}
+	return p.now.AddDate(0, 0, days)
+}
+
+// recurrence parses "daily", "weekly", "monthly" or an "every ..." phrase, with an
+// optional trailing "until <date>".
+func (p *parser) recurrence(i int) int {
+	if p.result.Recurrence != nil {
+		return 0
+	}
+	rec := &models.Recurrence{}
+	n := 0
+	switch p.word(i) {
+	case "daily":
+		rec.Freq, n = models.FreqDaily, 1
+	case "weekly":
+		rec.Freq, n = models.FreqWeekly, 1
+	case "monthly":
+		rec.Freq, n = models.FreqMonthly, 1
+	case "every":
+		n = p.every(i+1, rec)
+		if n == 0 {
+			return 0
+		}
+		n++
+	default:
+		return 0
+	}
+
+	if p.word(i+n) == "until" {
+		if d, m := p.dateAt(i + n + 1); m > 0 {
+			until := time.Date(d.year, d.month, d.day, 23, 59, 59, 0, p.now.Location()).UnixMilli()
+			rec.Until = &until
+			n += m + 1
+		}
+	}
+	p.result.Recurrence = rec
+	return n
+}
+
+func (p *parser) every(i int, rec *models.Recurrence) int {
+	unit := func(w string) string {
+		switch strings.TrimSuffix(w, "s") {
+		case "day":
+			return models.FreqDaily
+		case "week":
+			return models.FreqWeekly
+		case "month":
+			return models.FreqMonthly
+		}
+		return ""
+	}
+
+	w := p.word(i)
+	if f := unit(w); f != "" && !strings.HasSuffix(w, "s") {
+		rec.Freq = f
+		return 1
+	}
+	switch w {
+	case "weekday":
+		rec.Freq, rec.Weekdays = models.FreqWeekly, []string{"MO", "TU", "WE", "TH", "FR"}
+		return 1
+	case "weekend":
+		rec.Freq, rec.Weekdays = models.FreqWeekly, []string{"SA", "SU"}
+		return 1
+	case "other":
+		if f := unit(p.word(i + 1)); f != "" {
+			rec.Freq, rec.Interval = f, 2
+			return 2
+		}
+		return 0
+	}
+	if n, err := strconv.Atoi(w); err == nil && n > 0 {
+		if f := unit(p.word(i + 1)); f != "" {
+			rec.Freq, rec.Interval = f, n
+			return 2
+		}
+		return 0
+	}
+
+	// "every mon and thu", "every mon, thu", "every mon,thu"
+	n := 0
+	for {
+		w := p.word(i + n)
+		if w == "and" && n > 0 {
+			n++
+			continue
+		}
+		codes, ok := weekdayList(w)
+		if !ok {
+			break
+		}
+		rec.Weekdays = append(rec.Weekdays, codes...)
+		n++
+	}
+	if len(rec.Weekdays) == 0 {
+		return 0
+	}
+	if p.word(i+n-1) == "and" {
+		n--
+	}
+	rec.Freq = models.FreqWeekly
+	return n
+}
+
+func weekdayList(w string) ([]string, bool) {
+	var codes []string
+	for _, part := range strings.Split(w, ",") {
+		if part == "" {
+			continue
+		}
+		wd, ok := weekdays[part]
+		if !ok {
+			return nil, false
+		}
+		codes = append(codes, weekdayCodes[wd])
+	}
+	return codes, len(codes) > 0
+}
+
+// due combines the parsed date, time and recurrence into a due timestamp.
+func (p *parser) due() *int64 {
+	if p.exact != nil {
+		ms := p.exact.UnixMilli()
+		return &ms
+	}
+
+	loc := p.now.Location()
+	day := p.day
+	if day == (date{}) && !p.at.set && p.result.Recurrence == nil {
+		return nil
+	}
+
+	hour, minute := defaultHour, defaultMinute
+	if p.tonight {
+		hour, minute = tonightHour, 0
+	}
+	if p.at.set {
+		hour, minute = p.at.hour, p.at.minute
+	}
+
+	if day == (date{}) {
+		day = dateOf(p.now)
+		if rec := p.result.Recurrence; rec != nil && len(rec.Weekdays) > 0 {
+			day = p.firstWeekday(rec.Weekdays, hour, minute)
+		} else if day.at(hour, minute, loc).Before(p.now) {
+			// A bare time that already passed today means tomorrow.
+			day = dateOf(p.now.AddDate(0, 0, 1))
+		}
+	}
+
+	ms := day.at(hour, minute, loc).UnixMilli()
+	return &ms
+}
+
+// firstWeekday is the first day from today (inclusive, if the time has not passed)
+// that falls on one of the recurrence's weekdays.
+func (p *parser) firstWeekday(codes []string, hour, minute int) date {
+	for i := 0; i < 8; i++ {
+		t := p.now.AddDate(0, 0, i)
+		if dateOf(t).at(hour, minute, t.Location()).Before(p.now) {
+			continue
+		}
+		for _, c := range codes {
+			if weekdayCodes[t.Weekday()] == c {
+				return dateOf(t)
+			}
+		}
+	}
+	return dateOf(p.now)
+}
+
+func dateOf(t time.Time) date {
+	return date{t.Year(), t.Month(), t.Day()}
+}
+
+func (d date) normalize(loc *time.Location) date {
+	return dateOf(time.Date(d.year, d.month, d.day, 0, 0, 0, 0, loc))
+}
+
+// at is the instant of hour:minute on d in loc. A time skipped when clocks go forward
+// is read with the offset from before the change, so it lands as far past the change
+// as it was meant to be past the hour: 2:30 on a night that jumps from 2:00 to 3:00
+// is 3:30.
+func (d date) at(hour, minute int, loc *time.Location) time.Time {
+	t := time.Date(d.year, d.month, d.day, hour, minute, 0, 0, loc)
+	if t.Hour() == hour && t.Minute() == minute {
+		return t
+	}
+	_, before := t.Add(-12 * time.Hour).Zone()
+	naive := time.Date(d.year, d.month, d.day, hour, minute, 0, 0, time.UTC)
+	return naive.Add(-time.Duration(before) * time.Second).In(loc)
+}
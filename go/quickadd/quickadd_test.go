@@ -0,0 +1,298 @@
+package quickadd
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParse(t *testing.T) {
+	ny := mustLoad(t, "America/New_York")
+	tokyo := mustLoad(t, "Asia/Tokyo")
+
+	// Friday 6 March 2026, two days before clocks in New York go forward.
+	friday := time.Date(2026, 3, 6, 10, 0, 0, 0, ny)
+	at := func(loc *time.Location, y int, mo time.Month, d, h, m int) *time.Time {
+		t := time.Date(y, mo, d, h, m, 0, 0, loc)
+		return &t
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		now   time.Time
+		want  Result
+		due   *time.Time
+	}{
+		{
+			name:  "example",
+			input: "pay rent tomorrow 5pm #home !high every month",
+			now:   friday,
+			want: Result{
+				Text:       "pay rent",
+				Tags:       []string{"home"},
+				Priority:   models.PriorityHigh,
+				Recurrence: &models.Recurrence{Freq: models.FreqMonthly, TZ: "America/New_York"},
+			},
+			due: at(ny, 2026, 3, 7, 17, 0),
+		},
+		{
+			name:  "weekday is the next one",
+			input: "call mom friday",
+			now:   friday,
+			want:  Result{Text: "call mom"},
+			due:   at(ny, 2026, 3, 13, 23, 59),
+		},
+		{
+			name:  "next weekday",
+			input: "dentist next tue",
+			now:   friday,
+			want:  Result{Text: "dentist"},
+			due:   at(ny, 2026, 3, 10, 23, 59),
+		},
+		{
+			name:  "connectors with am time after DST",
+			input: "gym on monday at 7:30am",
+			now:   friday,
+			want:  Result{Text: "gym"},
+			due:   at(ny, 2026, 3, 9, 7, 30),
+		},
+		{
+			name:  "24h time later today",
+			input: "standup 14:30",
+			now:   friday,
+			want:  Result{Text: "standup"},
+			due:   at(ny, 2026, 3, 6, 14, 30),
+		},
+		{
+			name:  "two-word pm time",
+			input: "meet 5 pm",
+			now:   friday,
+			want:  Result{Text: "meet"},
+			due:   at(ny, 2026, 3, 6, 17, 0),
+		},
+		{
+			name:  "passed time means tomorrow",
+			input: "call back 9am",
+			now:   friday,
+			want:  Result{Text: "call back"},
+			due:   at(ny, 2026, 3, 7, 9, 0),
+		},
+		{
+			name:  "noon",
+			input: "lunch noon",
+			now:   friday,
+			want:  Result{Text: "lunch"},
+			due:   at(ny, 2026, 3, 6, 12, 0),
+		},
+		{
+			name:  "tonight",
+			input: "dinner tonight",
+			now:   friday,
+			want:  Result{Text: "dinner"},
+			due:   at(ny, 2026, 3, 6, 20, 0),
+		},
+		{
+			name:  "relative hours",
+			input: "send report in 2 hours",
+			now:   friday,
+			want:  Result{Text: "send report"},
+			due:   at(ny, 2026, 3, 6, 12, 0),
+		},
+		{
+			name:  "relative days",
+			input: "trip in 3 days",
+			now:   friday,
+			want:  Result{Text: "trip"},
+			due:   at(ny, 2026, 3, 9, 23, 59),
+		},
+		{
+			name:  "month and day",
+			input: "pay bills nov 20",
+			now:   friday,
+			want:  Result{Text: "pay bills"},
+			due:   at(ny, 2026, 11, 20, 23, 59),
+		},
+		{
+			name:  "slash date already passed this year",
+			input: "renew passport by 3/1",
+			now:   friday,
+			want:  Result{Text: "renew passport"},
+			due:   at(ny, 2027, 3, 1, 23, 59),
+		},
+		{
+			name:  "iso date",
+			input: "taxes 2026-04-15 9am",
+			now:   friday,
+			want:  Result{Text: "taxes"},
+			due:   at(ny, 2026, 4, 15, 9, 0),
+		},
+		{
+			name:  "invalid times stay text",
+			input: "meet 13pm 25:00",
+			now:   friday,
+			want:  Result{Text: "meet 13pm 25:00"},
+		},
+		{
+			name:  "weekday recurrence starts on the next matching day",
+			input: "piano every mon and thu 9am",
+			now:   friday,
+			want: Result{
+				Text:       "piano",
+				Recurrence: &models.Recurrence{Freq: models.FreqWeekly, Weekdays: []string{"MO", "TH"}, TZ: "America/New_York"},
+			},
+			due: at(ny, 2026, 3, 9, 9, 0),
+		},
+		{
+			name:  "interval recurrence",
+			input: "water plants every 2 weeks",
+			now:   friday,
+			want: Result{
+				Text:       "water plants",
+				Recurrence: &models.Recurrence{Freq: models.FreqWeekly, Interval: 2, TZ: "America/New_York"},
+			},
+			due: at(ny, 2026, 3, 6, 23, 59),
+		},
+
+		// Late in the evening in New York it is already the next day in UTC; dates
+		// follow the owner's zone.
+		{
+			name:  "tomorrow late evening",
+			input: "bins tomorrow",
+			now:   time.Date(2026, 3, 6, 22, 30, 0, 0, ny),
+			want:  Result{Text: "bins"},
+			due:   at(ny, 2026, 3, 7, 23, 59),
+		},
+		{
+			name:  "time later tonight",
+			input: "call 11pm",
+			now:   time.Date(2026, 3, 6, 22, 30, 0, 0, ny),
+			want:  Result{Text: "call"},
+			due:   at(ny, 2026, 3, 6, 23, 0),
+		},
+		{
+			name:  "time passed late evening rolls over",
+			input: "call 10pm",
+			now:   time.Date(2026, 3, 6, 22, 30, 0, 0, ny),
+			want:  Result{Text: "call"},
+			due:   at(ny, 2026, 3, 7, 22, 0),
+		},
+		{
+			name:  "early morning ahead of UTC",
+			input: "ship today 9pm",
+			now:   time.Date(2026, 3, 7, 8, 0, 0, 0, tokyo),
+			want:  Result{Text: "ship"},
+			due:   at(tokyo, 2026, 3, 7, 21, 0),
+		},
+
+		// DST: clocks in New York jump from 2:00 to 3:00 on 8 March 2026.
+		{
+			name:  "end of a short day",
+			input: "review sunday",
+			now:   friday,
+			want:  Result{Text: "review"},
+			due:   at(ny, 2026, 3, 8, 23, 59),
+		},
+		{
+			name:  "time skipped by DST moves forward",
+			input: "backup sunday 2:30am",
+			now:   friday,
+			want:  Result{Text: "backup"},
+			due:   ptr(time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC)),
+		},
+		{
+			name:  "relative hours across DST are elapsed time",
+			input: "check oven in 1 hour",
+			now:   time.Date(2026, 3, 8, 1, 30, 0, 0, ny),
+			want:  Result{Text: "check oven"},
+			due:   at(ny, 2026, 3, 8, 3, 30),
+		},
+		{
+			name:  "relative days across DST keep the wall clock",
+			input: "follow up in 1 day 9:00",
+			now:   time.Date(2026, 3, 7, 12, 0, 0, 0, ny),
+			want:  Result{Text: "follow up"},
+			due:   at(ny, 2026, 3, 8, 9, 0),
+		},
+
+		// # and ! only mark tags and priorities at the start of a word, and a leading
+		// backslash keeps any word as text.
+		{
+			name:  "escaped tokens",
+			input: `email \#general about \!high \tomorrow`,
+			now:   friday,
+			want:  Result{Text: "email #general about !high tomorrow"},
+		},
+		{
+			name:  "literal hash and bang",
+			input: "fix C# bug !important # now!",
+			now:   friday,
+			want:  Result{Text: "fix C# bug !important # now!"},
+		},
+
+		{
+			name:  "no date",
+			input: "buy milk #errands !low",
+			now:   friday,
+			want:  Result{Text: "buy milk", Tags: []string{"errands"}, Priority: models.PriorityLow},
+		},
+		{
+			name:  "nothing but tokens",
+			input: "#home !high tomorrow",
+			now:   friday,
+			want:  Result{Tags: []string{"home"}, Priority: models.PriorityHigh},
+			due:   at(ny, 2026, 3, 7, 23, 59),
+		},
+		{
+			name:  "empty",
+			input: "   ",
+			now:   friday,
+			want:  Result{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.input, tt.now)
+
+			want := tt.want
+			if tt.due != nil {
+				ms := tt.due.UnixMilli()
+				want.DueAt = &ms
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Parse(%q)\n got  %s\n want %s", tt.input, describe(got), describe(want))
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
+
+func describe(r Result) string {
+	s := "text=" + r.Text + " priority=" + r.Priority
+	if r.DueAt != nil {
+		s += " due=" + time.UnixMilli(*r.DueAt).UTC().Format(time.RFC3339)
+	}
+	for _, tag := range r.Tags {
+		s += " #" + tag
+	}
+	if r.Recurrence != nil {
+		s += fmt.Sprintf(" recurrence=%+v", *r.Recurrence)
+	}
+	return s
+}
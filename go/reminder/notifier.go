@@ -0,0 +1,45 @@
+package reminder
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	KindUpcoming = "upcoming"
+	KindOverdue  = "overdue"
+)
+
+// Event is one reminder about one todo.
+type Event struct {
+	UserID      string `json:"userId"`
+	TodoID      string `json:"todoId"`
+	Text        string `json:"text"`
+	DueAt       int64  `json:"dueAt"`
+	Kind        string `json:"kind"`                  // upcoming | overdue
+	LeadMinutes int    `json:"leadMinutes,omitempty"` // for upcoming: the lead time that fired
+	FireAt      int64  `json:"fireAt"`                // when the reminder became due, unix millis
+}
+
+// Notifier delivers reminder events. An error leaves the reminder unsent so a later
+// pass retries it.
+type Notifier interface {
+	Notify(ctx context.Context, e Event) error
+}
+
+// NotifierFunc adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, e Event) error
+
+func (f NotifierFunc) Notify(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// LogNotifier writes reminders to the server log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(_ context.Context, e Event) error {
+	log.Printf("reminder: %s todo %s for user %s (%q) due %s", e.Kind, e.TodoID, e.UserID, e.Text,
+		time.UnixMilli(e.DueAt).UTC().Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,158 @@
+// Package reminder sends reminders for todos that are coming up or past due.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/pet/sim"
+)
+
+// MaxOverdueAge is how long after its due time a todo can still get an overdue
+// reminder, so a service that was down for a while does not flood users.
+const MaxOverdueAge = 24 * time.Hour
+
+// ledgerRetention is how long after a todo's due time its sent reminders are kept.
+const ledgerRetention = 7 * 24 * time.Hour
+
+// TodoSource lists open todos due between two unix-millis instants, inclusive.
+type TodoSource interface {
+	ListDueTodos(ctx context.Context, after, before int64) ([]models.Todo, error)
+}
+
+// UserSource looks up users for their lead times; a nil user gets the defaults.
+type UserSource interface {
+	GetUserByID(ctx context.Context, userID string) (*models.User, error)
+}
+
+// Ledger remembers which reminders were sent. ClaimReminder reports false for a key
+// that was already claimed.
+type Ledger interface {
+	ClaimReminder(ctx context.Context, userID, key string, expiresAt int64) (bool, error)
+	ReleaseReminder(ctx context.Context, userID, key string) error
+}
+
+type Service struct {
+	todos    TodoSource
+	users    UserSource
+	ledger   Ledger
+	notifier Notifier
+	clock    sim.Clock // a sim.TestClock lets tests Tick at exact instants
+}
+
+func NewService(todos TodoSource, users UserSource, ledger Ledger, notifier Notifier, clock sim.Clock) *Service {
+	return &Service{
+		todos:    todos,
+		users:    users,
+		ledger:   ledger,
+		notifier: notifier,
+		clock:    clock,
+	}
+}
+
+// Run calls Tick every interval until ctx is done, logging failed passes.
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := s.Tick(ctx); err != nil {
+			log.Println("reminder pass failed:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Tick sends every reminder that is due at the clock's current time and has not been
+// sent yet. A todo gets one upcoming reminder per lead time as it comes within that
+// lead of its due time, and one overdue reminder once the due time passes. When
+// several lead times have passed since the last pass only the nearest one fires, and
+// none fire once the todo is overdue. A failure for one todo does not hold up the rest.
+func (s *Service) Tick(ctx context.Context) error {
+	now := s.clock.Now()
+	todos, err := s.todos.ListDueTodos(ctx, now.Add(-MaxOverdueAge).UnixMilli(), now.Add(models.MaxReminderLead).UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	leads := map[string][]time.Duration{}
+	for _, t := range todos {
+		if t.DueAt == nil {
+			continue
+		}
+		userLeads, ok := leads[t.UserID]
+		if !ok {
+			user, err := s.users.GetUserByID(ctx, t.UserID)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			userLeads = user.ReminderLeads()
+			leads[t.UserID] = userLeads
+		}
+
+		if e, ok := eventFor(t, userLeads, now); ok {
+			if err := s.send(ctx, e); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// eventFor picks the reminder t is due for at now, if any.
+func eventFor(t models.Todo, leads []time.Duration, now time.Time) (Event, bool) {
+	due := time.UnixMilli(*t.DueAt)
+	e := Event{UserID: t.UserID, TodoID: t.TodoID, Text: t.Text, DueAt: *t.DueAt}
+
+	if !now.Before(due) {
+		if now.Sub(due) > MaxOverdueAge {
+			return e, false
+		}
+		e.Kind = KindOverdue
+		e.FireAt = *t.DueAt
+		return e, true
+	}
+
+	var nearest time.Duration
+	for _, lead := range leads {
+		if now.Before(due.Add(-lead)) || (nearest > 0 && lead >= nearest) {
+			continue
+		}
+		nearest = lead
+	}
+	if nearest == 0 {
+		return e, false
+	}
+	e.Kind = KindUpcoming
+	e.LeadMinutes = int(nearest / time.Minute)
+	e.FireAt = due.Add(-nearest).UnixMilli()
+	return e, true
+}
+
+// send delivers e at most once per todo, due time, kind and lead; rescheduling a
+// todo therefore re-arms its reminders.
+func (s *Service) send(ctx context.Context, e Event) error {
+	key := fmt.Sprintf("%s#%d#%s#%d", e.TodoID, e.DueAt, e.Kind, e.LeadMinutes)
+	expiresAt := time.UnixMilli(e.DueAt).Add(ledgerRetention).Unix()
+
+	claimed, err := s.ledger.ClaimReminder(ctx, e.UserID, key, expiresAt)
+	if err != nil || !claimed {
+		return err
+	}
+	if err := s.notifier.Notify(ctx, e); err != nil {
+		if rerr := s.ledger.ReleaseReminder(ctx, e.UserID, key); rerr != nil {
+			return rerr
+		}
+		return err
+	}
+	return nil
+}
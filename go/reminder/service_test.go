@@ -0,0 +1,197 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+	"github.com/juhun32/patriot25-gochi/pet/sim"
+)
+
+type fakeSource struct{ todos []models.Todo }
+
+func (f *fakeSource) ListDueTodos(_ context.Context, after, before int64) ([]models.Todo, error) {
+	var due []models.Todo
+	for _, t := range f.todos {
+		if !t.Done && t.DueAt != nil && *t.DueAt >= after && *t.DueAt <= before {
+			due = append(due, t)
+		}
+	}
+	return due, nil
+}
+
+type fakeUsers map[string]*models.User
+
+func (f fakeUsers) GetUserByID(_ context.Context, userID string) (*models.User, error) {
+	return f[userID], nil
+}
+
+type fakeLedger map[string]bool
+
+func (f fakeLedger) ClaimReminder(_ context.Context, userID, key string, _ int64) (bool, error) {
+	if f[userID+"/"+key] {
+		return false, nil
+	}
+	f[userID+"/"+key] = true
+	return true, nil
+}
+
+func (f fakeLedger) ReleaseReminder(_ context.Context, userID, key string) error {
+	delete(f, userID+"/"+key)
+	return nil
+}
+
+// A step moves the clock to at, reschedules the todo to due if set, runs a pass and
+// expects the reminders in want, written as "upcoming 60" or "overdue".
+type step struct {
+	at      time.Duration
+	due     time.Duration
+	failing bool // the notifier fails this pass
+	want    []string
+}
+
+func TestTick(t *testing.T) {
+	base := time.Date(2026, 3, 8, 9, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name    string
+		due     time.Duration // the todo's initial due time
+		leads   []int
+		claimed []string // ledger keys another instance already claimed, minus the todo ID
+		steps   []step
+	}{
+		{
+			name: "fires on time",
+			due:  2 * time.Hour,
+			steps: []step{
+				{at: 59 * time.Minute},
+				{at: time.Hour, want: []string{"upcoming 60"}},
+				{at: 119 * time.Minute},
+				{at: 2 * time.Hour, want: []string{"overdue"}},
+				{at: 3 * time.Hour},
+			},
+		},
+		{
+			name:  "fires each lead once",
+			due:   2 * time.Hour,
+			leads: []int{15, 60},
+			steps: []step{
+				{at: time.Hour, want: []string{"upcoming 60"}},
+				{at: 90 * time.Minute},
+				{at: 105 * time.Minute, want: []string{"upcoming 15"}},
+				{at: 110 * time.Minute},
+			},
+		},
+		{
+			name:    "skips reminders already in the ledger",
+			due:     2 * time.Hour,
+			claimed: []string{fmt.Sprintf("%d#upcoming#60", base.Add(2*time.Hour).UnixMilli())},
+			steps: []step{
+				{at: time.Hour},
+				{at: 2 * time.Hour, want: []string{"overdue"}},
+			},
+		},
+		{
+			name: "retries after the notifier fails",
+			due:  2 * time.Hour,
+			steps: []step{
+				{at: time.Hour, failing: true},
+				{at: 61 * time.Minute, want: []string{"upcoming 60"}},
+				{at: 62 * time.Minute},
+			},
+		},
+		{
+			name: "re-arms when snoozed",
+			due:  2 * time.Hour,
+			steps: []step{
+				{at: time.Hour, want: []string{"upcoming 60"}},
+				{at: 90 * time.Minute, due: 4 * time.Hour},
+				{at: 3 * time.Hour, want: []string{"upcoming 60"}},
+				{at: 4 * time.Hour, want: []string{"overdue"}},
+			},
+		},
+		{
+			name:  "fires only the nearest lead when the due time moves closer",
+			due:   2 * 24 * time.Hour,
+			leads: []int{10, 60, 24 * 60},
+			steps: []step{
+				{at: 24 * time.Hour, want: []string{"upcoming 1440"}},
+				{at: 25 * time.Hour, due: 25*time.Hour + 30*time.Minute, want: []string{"upcoming 60"}},
+				{at: 25*time.Hour + 20*time.Minute, want: []string{"upcoming 10"}},
+			},
+		},
+		{
+			name: "sends one overdue reminder for a due time passed before startup",
+			due:  -30 * time.Minute,
+			steps: []step{
+				{at: 0, want: []string{"overdue"}},
+				{at: time.Minute},
+			},
+		},
+		{
+			name:  "sends the nearest lead for leads passed before startup",
+			due:   20 * time.Minute,
+			leads: []int{30, 60},
+			steps: []step{
+				{at: 0, want: []string{"upcoming 30"}},
+				{at: 20 * time.Minute, want: []string{"overdue"}},
+			},
+		},
+		{
+			name: "stays quiet for a todo overdue longer than MaxOverdueAge at startup",
+			due:  -MaxOverdueAge - time.Minute,
+			steps: []step{
+				{at: 0},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			due := base.Add(tc.due).UnixMilli()
+			source := &fakeSource{todos: []models.Todo{{UserID: "u1", TodoID: "t1", Text: "pay rent", DueAt: &due}}}
+			users := fakeUsers{"u1": {UserID: "u1", ReminderLeadMinutes: tc.leads}}
+			ledger := fakeLedger{}
+			for _, key := range tc.claimed {
+				ledger["u1/t1#"+key] = true
+			}
+
+			var failing bool
+			var sent []string
+			notifier := NotifierFunc(func(_ context.Context, e Event) error {
+				if failing {
+					return errors.New("notifier down")
+				}
+				if e.Kind == KindUpcoming {
+					sent = append(sent, fmt.Sprintf("%s %d", e.Kind, e.LeadMinutes))
+				} else {
+					sent = append(sent, e.Kind)
+				}
+				return nil
+			})
+
+			clock := sim.NewTestClock(base)
+			s := NewService(source, users, ledger, notifier, clock)
+			for i, st := range tc.steps {
+				clock.Set(base.Add(st.at))
+				if st.due != 0 {
+					rescheduled := base.Add(st.due).UnixMilli()
+					source.todos[0].DueAt = &rescheduled
+				}
+				failing = st.failing
+				sent = nil
+
+				err := s.Tick(ctx)
+				if st.failing != (err != nil) {
+					t.Errorf("step %d: Tick error %v", i, err)
+				}
+				if !reflect.DeepEqual(sent, st.want) {
+					t.Errorf("step %d at %v: sent %q, want %q", i, st.at, sent, st.want)
+				}
+			}
+		})
+	}
+}
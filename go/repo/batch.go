@@ -0,0 +1,88 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// DynamoDB service limits for batch APIs.
+const (
+	maxBatchWrite = 25
+	maxBatchGet   = 100
+	maxBatchRetry = 5
+)
+
+// batchWrite sends writes in chunks of 25, resubmitting unprocessed items.
+func batchWrite(ctx context.Context, client *dynamodb.Client, table string, writes []types.WriteRequest) error {
+	for start := 0; start < len(writes); start += maxBatchWrite {
+		end := min(start+maxBatchWrite, len(writes))
+		pending := map[string][]types.WriteRequest{table: writes[start:end]}
+		for attempt := 0; len(pending[table]) > 0; attempt++ {
+			if attempt == maxBatchRetry {
+				return fmt.Errorf("batch write to %s: %d items left unprocessed", table, len(pending[table]))
+			}
+			out, err := client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				return err
+			}
+			pending = out.UnprocessedItems
+		}
+	}
+	return nil
+}
+
+// batchGetTodos fetches todos by ID, returning them in the order requested and
+// silently skipping IDs that no longer exist.
+func (r *TodoRepo) batchGetTodos(ctx context.Context, userID string, todoIDs []string) ([]models.Todo, error) {
+	byID := make(map[string]models.Todo, len(todoIDs))
+	for start := 0; start < len(todoIDs); start += maxBatchGet {
+		end := min(start+maxBatchGet, len(todoIDs))
+		keys := make([]map[string]types.AttributeValue, 0, end-start)
+		seen := map[string]bool{}
+		for _, id := range todoIDs[start:end] {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			key, err := todoKey(userID, id)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+
+		pending := map[string]types.KeysAndAttributes{r.tableName: {Keys: keys}}
+		for attempt := 0; len(pending[r.tableName].Keys) > 0; attempt++ {
+			if attempt == maxBatchRetry {
+				return nil, fmt.Errorf("batch get from %s: keys left unprocessed", r.tableName)
+			}
+			out, err := r.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: pending})
+			if err != nil {
+				return nil, err
+			}
+			var todos []models.Todo
+			if err := attributevalue.UnmarshalListOfMaps(out.Responses[r.tableName], &todos); err != nil {
+				return nil, err
+			}
+			for _, t := range todos {
+				byID[t.TodoID] = t
+			}
+			pending = out.UnprocessedKeys
+		}
+	}
+
+	result := make([]models.Todo, 0, len(byID))
+	for _, id := range todoIDs {
+		if t, ok := byID[id]; ok {
+			result = append(result, t)
+			delete(byID, id)
+		}
+	}
+	return result, nil
+}
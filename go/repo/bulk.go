@@ -0,0 +1,416 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+const (
+	BatchCreate   = "create"
+	BatchComplete = "complete"
+	BatchUpdate   = "update"
+	BatchDelete   = "delete"
+)
+
+// MaxBatchOps matches the TransactWriteItems limit so either mode accepts the same input.
+const MaxBatchOps = 100
+
+var (
+	ErrBatchTooLarge = fmt.Errorf("a batch can hold at most %d operations", MaxBatchOps)
+	// ErrBatchAborted is returned with the per-item results when a transactional batch
+	// was rejected as a whole.
+	ErrBatchAborted = errors.New("transactional batch aborted")
+)
+
+type BatchOp struct {
+	Op        string
+	TodoID    string       // complete, update and delete
+	Todo      *models.Todo // create; IDs already assigned by the caller
+	Update    TodoUpdate   // update
+	IfVersion *int64       // optional, like If-Match on the single-item endpoints
+}
+
+type BatchResult struct {
+	Op     string       `json:"op"`
+	TodoID string       `json:"todoId,omitempty"`
+	OK     bool         `json:"ok"`
+	Todo   *models.Todo `json:"todo,omitempty"`
+	Error  string       `json:"error,omitempty"`
+}
+
+// stagedOp is an operation that passed validation and knows what it will write.
+type stagedOp struct {
+	index int
+	old   *models.Todo // nil for creates
+	next  *models.Todo
+}
+
+// ApplyBatch runs ops against the user's todos; deletes move todos to the trash just
+// like DeleteTodo. By default each operation succeeds or fails on its own: creates are
+// written with BatchWriteItem in chunks of 25, and every other operation with a put
+// guarded by the version it was staged from, so a todo that changed since the batch
+// read it fails with ErrPreconditionFailed instead of being overwritten. With
+// transactional set, everything goes through one TransactWriteItems call guarded by
+// the same conditions, and nothing is written unless every operation can be.
+func (r *TodoRepo) ApplyBatch(ctx context.Context, userID string, ops []BatchOp, transactional bool) ([]BatchResult, error) {
+	if len(ops) > MaxBatchOps {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchResult, len(ops))
+	staged, err := r.stageBatch(ctx, userID, ops, results)
+	if err != nil {
+		return nil, err
+	}
+
+	if transactional {
+		if len(staged) < len(ops) {
+			markAborted(results)
+			return results, ErrBatchAborted
+		}
+		if err := r.writeTransaction(ctx, staged, results); err != nil {
+			return results, err
+		}
+	} else if err := r.writeBatch(ctx, staged, results); err != nil {
+		return nil, err
+	}
+
+	return results, r.afterBatch(ctx, userID, staged, results)
+}
+
+// stageBatch loads the todos the batch touches and computes each operation's new
+// state. Operations that cannot run get their error recorded in results.
+func (r *TodoRepo) stageBatch(ctx context.Context, userID string, ops []BatchOp, results []BatchResult) ([]stagedOp, error) {
+	var ids []string
+	seen := map[string]bool{}
+	for i, op := range ops {
+		id := op.TodoID
+		if op.Op == BatchCreate && op.Todo != nil {
+			id = op.Todo.TodoID
+		}
+		results[i] = BatchResult{Op: op.Op, TodoID: id}
+		if seen[id] {
+			results[i].Error = "todo appears more than once in the batch"
+			continue
+		}
+		seen[id] = true
+		if op.Op != BatchCreate {
+			ids = append(ids, id)
+		}
+	}
+
+	existing, err := r.batchGetTodos(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]models.Todo, len(existing))
+	for _, t := range existing {
+		if !t.Trashed() {
+			byID[t.TodoID] = t
+		}
+	}
+
+	// Projects the batch files todos under are checked once each. Missing and
+	// archived projects fail only the operations naming them.
+	projects := map[string]error{}
+	checkProject := func(projectID string) (string, error) {
+		err, ok := projects[projectID]
+		if !ok {
+			err = r.checkProject(ctx, userID, projectID)
+			projects[projectID] = err
+		}
+		if errors.Is(err, ErrProjectNotFound) || errors.Is(err, ErrProjectArchived) {
+			return err.Error(), nil
+		}
+		return "", err
+	}
+
+	now := time.Now().UnixMilli()
+	var rank string
+	var staged []stagedOp
+	for i, op := range ops {
+		if results[i].Error != "" {
+			continue
+		}
+
+		s := stagedOp{index: i}
+		if op.Op != BatchCreate {
+			old, ok := byID[op.TodoID]
+			if !ok {
+				results[i].Error = ErrTodoNotFound.Error()
+				continue
+			}
+			if op.IfVersion != nil && *op.IfVersion != old.Version {
+				results[i].Error = ErrPreconditionFailed.Error()
+				continue
+			}
+			s.old = &old
+		}
+
+		switch op.Op {
+		case BatchCreate:
+			if op.Todo == nil || op.Todo.UserID != userID {
+				results[i].Error = "create needs a todo"
+				continue
+			}
+			if results[i].Error, err = checkProject(op.Todo.ProjectID); err != nil {
+				return nil, err
+			} else if results[i].Error != "" {
+				continue
+			}
+			next := *op.Todo
+			if next.Rank == "" {
+				if rank == "" {
+					if rank, err = r.lastRank(ctx, userID); err != nil {
+						return nil, err
+					}
+				}
+				rank = rankAfter(rank)
+				next.SetRank(rank)
+			}
+			next.CreatedAt, next.UpdatedAt, next.Version = now, now, 1
+			stampCompletion(nil, &next, now)
+			s.next = &next
+		case BatchComplete, BatchUpdate:
+			if op.Update.ProjectID != nil {
+				if results[i].Error, err = checkProject(*op.Update.ProjectID); err != nil {
+					return nil, err
+				} else if results[i].Error != "" {
+					continue
+				}
+			}
+			next := *s.old
+			if op.Op == BatchComplete {
+				next.Done = true
+			} else {
+				op.Update.apply(&next)
+			}
+			next.UpdatedAt = now
+			next.Version++
+			stampCompletion(s.old, &next, now)
+			s.next = &next
+		case BatchDelete:
+			next := *s.old
+			r.trash(&next, time.UnixMilli(now))
+			next.UpdatedAt = now
+			next.Version++
+			s.next = &next
+		default:
+			results[i].Error = "unknown op " + op.Op
+			continue
+		}
+		staged = append(staged, s)
+	}
+	return staged, nil
+}
+
+func (r *TodoRepo) writeBatch(ctx context.Context, staged []stagedOp, results []BatchResult) error {
+	var creates []stagedOp
+	for _, s := range staged {
+		if s.old == nil {
+			creates = append(creates, s)
+			continue
+		}
+		if err := r.putStaged(ctx, s, &results[s.index]); err != nil {
+			return err
+		}
+	}
+
+	for start := 0; start < len(creates); start += maxBatchWrite {
+		chunk := creates[start:min(start+maxBatchWrite, len(creates))]
+
+		byID := map[string]stagedOp{}
+		writes := make([]types.WriteRequest, 0, len(chunk))
+		for _, s := range chunk {
+			item, err := todoItem(s.next)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+			byID[s.next.TodoID] = s
+		}
+
+		pending := map[string][]types.WriteRequest{r.tableName: writes}
+		var callErr error
+		for attempt := 0; attempt < maxBatchRetry && len(pending[r.tableName]) > 0; attempt++ {
+			out, err := r.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: pending})
+			if err != nil {
+				callErr = err
+				break
+			}
+			pending = out.UnprocessedItems
+		}
+
+		failed := map[string]bool{}
+		for _, w := range pending[r.tableName] {
+			failed[writeTodoID(w)] = true
+		}
+		for id, s := range byID {
+			res := &results[s.index]
+			switch {
+			case callErr != nil:
+				res.Error = callErr.Error()
+			case failed[id]:
+				res.Error = "throttled; try again"
+			default:
+				res.OK = true
+				res.Todo = s.next
+			}
+		}
+	}
+	return nil
+}
+
+// putStaged writes an operation on an existing todo if the todo is still at the
+// version it was staged from, recording the outcome in res.
+func (r *TodoRepo) putStaged(ctx context.Context, s stagedOp, res *BatchResult) error {
+	item, err := todoItem(s.next)
+	if err != nil {
+		return err
+	}
+	cond, values := versionCondition(s.old.Version)
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:                 &r.tableName,
+		Item:                      item,
+		ConditionExpression:       cond,
+		ExpressionAttributeValues: values,
+	})
+	var ccf *types.ConditionalCheckFailedException
+	switch {
+	case errors.As(err, &ccf):
+		res.Error = ErrPreconditionFailed.Error()
+	case err != nil:
+		res.Error = err.Error()
+	default:
+		res.OK = true
+		res.Todo = s.next
+	}
+	return nil
+}
+
+func (r *TodoRepo) writeTransaction(ctx context.Context, staged []stagedOp, results []BatchResult) error {
+	items := make([]types.TransactWriteItem, 0, len(staged))
+	for _, s := range staged {
+		switch {
+		case s.old == nil:
+			item, err := todoItem(s.next)
+			if err != nil {
+				return err
+			}
+			items = append(items, types.TransactWriteItem{Put: &types.Put{
+				TableName:           &r.tableName,
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(todoId)"),
+			}})
+		default:
+			item, err := todoItem(s.next)
+			if err != nil {
+				return err
+			}
+			cond, values := versionCondition(s.old.Version)
+			items = append(items, types.TransactWriteItem{Put: &types.Put{
+				TableName:                 &r.tableName,
+				Item:                      item,
+				ConditionExpression:       cond,
+				ExpressionAttributeValues: values,
+			}})
+		}
+	}
+
+	_, err := r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		markAborted(results)
+		for i, reason := range canceled.CancellationReasons {
+			if i < len(staged) && aws.ToString(reason.Code) == "ConditionalCheckFailed" {
+				results[staged[i].index].Error = ErrPreconditionFailed.Error()
+			}
+		}
+		return ErrBatchAborted
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, s := range staged {
+		results[s.index].OK = true
+		results[s.index].Todo = s.next
+	}
+	return nil
+}
+
+// afterBatch does for the writes that succeeded what afterWrite does for single-item
+// paths, batching the tag index and history writes and updating the pet once.
+func (r *TodoRepo) afterBatch(ctx context.Context, userID string, staged []stagedOp, results []BatchResult) error {
+	var tagReqs, historyReqs []types.WriteRequest
+	for _, s := range staged {
+		if !results[s.index].OK {
+			continue
+		}
+		r.indexTodo(s.next)
+		w, err := tagWrites(userID, s.next.TodoID, indexedTags(s.old), indexedTags(s.next))
+		if err != nil {
+			return err
+		}
+		tagReqs = append(tagReqs, w...)
+
+		entry, err := historyEntry(ctx, s.old, s.next)
+		if err != nil {
+			return err
+		}
+		if entry != nil {
+			h, err := historyWrite(entry)
+			if err != nil {
+				return err
+			}
+			historyReqs = append(historyReqs, h)
+		}
+	}
+	if err := batchWrite(ctx, r.client, r.tagTableName, tagReqs); err != nil {
+		return err
+	}
+	if err := batchWrite(ctx, r.client, r.historyTableName, historyReqs); err != nil {
+		return err
+	}
+
+	var changes []petChange
+	for _, s := range staged {
+		if !results[s.index].OK {
+			continue
+		}
+		changes = append(changes, petChange{s.old, s.next})
+		if err := r.spawnNextOccurrence(ctx, s.next); err != nil {
+			return err
+		}
+	}
+	r.updatePet(ctx, userID, changes...)
+	return nil
+}
+
+func writeTodoID(w types.WriteRequest) string {
+	if w.PutRequest == nil {
+		return ""
+	}
+	if v, ok := w.PutRequest.Item["todoId"].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func markAborted(results []BatchResult) {
+	for i := range results {
+		results[i].OK = false
+		results[i].Todo = nil
+		if results[i].Error == "" {
+			results[i].Error = "not applied: batch aborted"
+		}
+	}
+}
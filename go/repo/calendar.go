@@ -0,0 +1,44 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SetCalendarEvent records that the todo at version is in sync with calendar event
+// eventID, or, with an empty eventID, that it no longer has an event. It is sync
+// bookkeeping rather than an edit, so it leaves Version and the history alone. If
+// the todo has changed since version it returns ErrPreconditionFailed and the next
+// sync pass picks the change up.
+func (r *TodoRepo) SetCalendarEvent(ctx context.Context, userID, todoID, eventID string, version int64) error {
+	key, err := todoKey(userID, todoID)
+	if err != nil {
+		return err
+	}
+	values := map[string]types.AttributeValue{
+		":version": &types.AttributeValueMemberN{Value: strconv.FormatInt(version, 10)},
+	}
+	update := "SET calendarVersion = :version REMOVE calendarEventId"
+	if eventID != "" {
+		update = "SET calendarVersion = :version, calendarEventId = :event"
+		values[":event"] = &types.AttributeValueMemberS{Value: eventID}
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                 &r.tableName,
+		Key:                       key,
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String("version = :version"),
+		ExpressionAttributeValues: values,
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return ErrPreconditionFailed
+	}
+	return err
+}
@@ -0,0 +1,516 @@
+package repo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// Table names the test repos are built with.
+const (
+	testTodos    = "todos"
+	testTags     = "tags"
+	testHistory  = "history"
+	testProjects = "projects"
+	testSessions = "sessions"
+	testShares   = "shares"
+	testPets     = "pets"
+)
+
+// fakeKeys is the key schema of each table and index the fake serves: a partition key
+// and a sort key.
+var fakeKeys = map[string][2]string{
+	testTodos:          {"userId", "todoId"},
+	testTags:           {"userId", "tagKey"},
+	testHistory:        {"userId", "historyKey"},
+	testProjects:       {"userId", "projectId"},
+	testSessions:       {"userId", "sessionKey"},
+	testShares:         {"userId", "shareKey"},
+	testPets:           {"userId", ""},
+	todoRankIndex:      {"userId", "rankKey"},
+	todoDueIndex:       {"dueShard", "remindAt"},
+	shareOwnerIndex:    {"ownerId", "itemKey"},
+	userEmailIndex:     {"email", ""},
+	userFeedTokenIndex: {"feedToken", ""},
+}
+
+// item is a DynamoDB item in its wire form: attribute name to {"S": ...}, {"N": ...}
+// and so on.
+type item = map[string]map[string]any
+
+// fakeDynamo serves enough of the DynamoDB JSON API over HTTP for the repos to run
+// against: single-item reads and writes, Scan, Query, batch reads and writes and
+// transactions of puts and deletes, with condition, filter and key expressions made of
+// comparisons and attribute_exists or attribute_not_exists joined by AND. Indexes
+// contain every item that has their keys.
+type fakeDynamo struct {
+	t      *testing.T
+	mu     sync.Mutex
+	tables map[string][]item
+}
+
+// newTestRepo returns a todo repo, with its pet repo, backed by a fresh fakeDynamo.
+func newTestRepo(t *testing.T) (*TodoRepo, *fakeDynamo) {
+	f := &fakeDynamo{t: t, tables: map[string][]item{}}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+
+	client := dynamodb.New(dynamodb.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "test", SecretAccessKey: "test"}, nil
+		}),
+		RetryMaxAttempts: 1,
+	})
+	pets := NewPetStateRepo(client, testPets, models.DefaultGrowth)
+	todos := NewTodoRepo(client, testTodos, testTags, testHistory, testProjects, testSessions, testShares, pets, models.DefaultScoring, 30*24*time.Hour)
+	return todos, f
+}
+
+// put stores v, marshalled as the repos marshal it, in table.
+func (f *fakeDynamo) put(table string, v any) {
+	f.t.Helper()
+	av, err := attributevalue.MarshalMap(v)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	wire := map[string]any{}
+	for k, v := range av {
+		wire[k] = wireValue(v)
+	}
+	raw, err := json.Marshal(wire)
+	if err != nil {
+		f.t.Fatal(err)
+	}
+	var it item
+	if err := json.Unmarshal(raw, &it); err != nil {
+		f.t.Fatal(err)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.upsert(table, it)
+}
+
+type fakeRequest struct {
+	TableName                 string
+	IndexName                 string
+	Key                       item
+	Item                      item
+	ConditionExpression       string
+	UpdateExpression          string
+	FilterExpression          string
+	KeyConditionExpression    string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues item
+	ScanIndexForward          *bool
+	Limit                     int
+	RequestItems              json.RawMessage // shaped by the batch operation
+	TransactItems             []struct{ Put, Delete *fakeRequest }
+}
+
+type fakeBatchWrite map[string][]struct {
+	PutRequest    *struct{ Item item }
+	DeleteRequest *struct{ Key item }
+}
+
+type fakeBatchGet map[string]struct{ Keys []item }
+
+type fakeError struct {
+	kind, msg string
+}
+
+func (f *fakeDynamo) serve(w http.ResponseWriter, r *http.Request) {
+	op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810.")
+	var req fakeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		f.t.Errorf("fake dynamo: %s: %v", op, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	out, ferr := f.handle(op, &req)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if ferr != nil {
+		if ferr.kind == "ValidationException" {
+			f.t.Errorf("fake dynamo: %s: %s", op, ferr.msg)
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"__type":  "com.amazonaws.dynamodb.v20120810#" + ferr.kind,
+			"message": ferr.msg,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+func (f *fakeDynamo) handle(op string, req *fakeRequest) (map[string]any, *fakeError) {
+	switch op {
+	case "GetItem":
+		if _, it := f.find(req.TableName, req.Key); it != nil {
+			return map[string]any{"Item": it}, nil
+		}
+		return map[string]any{}, nil
+
+	case "PutItem":
+		_, old := f.find(req.TableName, f.keyOf(req.TableName, req.Item))
+		if err := f.check(req, old); err != nil {
+			return nil, err
+		}
+		f.upsert(req.TableName, req.Item)
+		return map[string]any{}, nil
+
+	case "DeleteItem":
+		_, old := f.find(req.TableName, req.Key)
+		if err := f.check(req, old); err != nil {
+			return nil, err
+		}
+		f.remove(req.TableName, req.Key)
+		return map[string]any{}, nil
+
+	case "TransactWriteItems":
+		for _, tx := range req.TransactItems {
+			write, key := tx.Put, item(nil)
+			if write != nil {
+				key = f.keyOf(write.TableName, write.Item)
+			} else {
+				write, key = tx.Delete, tx.Delete.Key
+			}
+			_, old := f.find(write.TableName, key)
+			if err := f.check(write, old); err != nil {
+				if err.kind == "ConditionalCheckFailedException" {
+					err.kind = "TransactionCanceledException"
+				}
+				return nil, err
+			}
+		}
+		for _, tx := range req.TransactItems {
+			if tx.Put != nil {
+				f.upsert(tx.Put.TableName, tx.Put.Item)
+			} else {
+				f.remove(tx.Delete.TableName, tx.Delete.Key)
+			}
+		}
+		return map[string]any{}, nil
+
+	case "UpdateItem":
+		_, old := f.find(req.TableName, req.Key)
+		if err := f.check(req, old); err != nil {
+			return nil, err
+		}
+		next := item{}
+		for k, v := range req.Key {
+			next[k] = v
+		}
+		for k, v := range old {
+			next[k] = v
+		}
+		if err := f.update(req, next); err != nil {
+			return nil, err
+		}
+		f.upsert(req.TableName, next)
+		return map[string]any{}, nil
+
+	case "BatchGetItem":
+		var batch fakeBatchGet
+		if err := json.Unmarshal(req.RequestItems, &batch); err != nil {
+			return nil, &fakeError{"ValidationException", err.Error()}
+		}
+		responses := map[string][]item{}
+		for table, get := range batch {
+			responses[table] = []item{}
+			for _, key := range get.Keys {
+				if _, it := f.find(table, key); it != nil {
+					responses[table] = append(responses[table], it)
+				}
+			}
+		}
+		return map[string]any{"Responses": responses, "UnprocessedKeys": map[string]any{}}, nil
+
+	case "BatchWriteItem":
+		var batch fakeBatchWrite
+		if err := json.Unmarshal(req.RequestItems, &batch); err != nil {
+			return nil, &fakeError{"ValidationException", err.Error()}
+		}
+		for table, writes := range batch {
+			for _, wr := range writes {
+				switch {
+				case wr.PutRequest != nil:
+					f.upsert(table, wr.PutRequest.Item)
+				case wr.DeleteRequest != nil:
+					f.remove(table, wr.DeleteRequest.Key)
+				}
+			}
+		}
+		return map[string]any{"UnprocessedItems": map[string]any{}}, nil
+
+	case "Scan", "Query":
+		schema := req.TableName
+		if req.IndexName != "" {
+			schema = req.IndexName
+		}
+		keys := fakeKeys[schema]
+		var rows []item
+		for _, it := range f.tables[req.TableName] {
+			if it[keys[0]] == nil || (keys[1] != "" && it[keys[1]] == nil) {
+				continue
+			}
+			if op == "Query" {
+				ok, err := f.eval(req.KeyConditionExpression, req, it)
+				if err != nil {
+					return nil, err
+				}
+				if !ok {
+					continue
+				}
+			}
+			rows = append(rows, it)
+		}
+		if keys[1] != "" {
+			sort.SliceStable(rows, func(i, j int) bool {
+				return less(rows[i][keys[1]], rows[j][keys[1]])
+			})
+		}
+		if req.ScanIndexForward != nil && !*req.ScanIndexForward {
+			for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+				rows[i], rows[j] = rows[j], rows[i]
+			}
+		}
+		if req.Limit > 0 && len(rows) > req.Limit {
+			rows = rows[:req.Limit]
+		}
+		items := []item{}
+		for _, it := range rows {
+			ok, err := f.eval(req.FilterExpression, req, it)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				items = append(items, it)
+			}
+		}
+		return map[string]any{"Items": items, "Count": len(items)}, nil
+	}
+	return nil, &fakeError{"ValidationException", "unsupported operation " + op}
+}
+
+func (f *fakeDynamo) keyOf(table string, it item) item {
+	keys := fakeKeys[table]
+	key := item{keys[0]: it[keys[0]]}
+	if keys[1] != "" {
+		key[keys[1]] = it[keys[1]]
+	}
+	return key
+}
+
+func (f *fakeDynamo) find(table string, key item) (int, item) {
+	for i, it := range f.tables[table] {
+		if reflect.DeepEqual(f.keyOf(table, it), f.keyOf(table, key)) {
+			return i, it
+		}
+	}
+	return -1, nil
+}
+
+func (f *fakeDynamo) upsert(table string, it item) {
+	if i, old := f.find(table, it); old != nil {
+		f.tables[table][i] = it
+		return
+	}
+	f.tables[table] = append(f.tables[table], it)
+}
+
+func (f *fakeDynamo) remove(table string, key item) {
+	if i, old := f.find(table, key); old != nil {
+		rows := f.tables[table]
+		f.tables[table] = append(rows[:i:i], rows[i+1:]...)
+	}
+}
+
+func (f *fakeDynamo) check(req *fakeRequest, old item) *fakeError {
+	if old == nil {
+		old = item{}
+	}
+	ok, err := f.eval(req.ConditionExpression, req, old)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &fakeError{"ConditionalCheckFailedException", "The conditional request failed"}
+	}
+	return nil
+}
+
+var (
+	fakeFuncRe    = regexp.MustCompile(`^(attribute_exists|attribute_not_exists)\(\s*([#\w]+)\s*\)$`)
+	fakeCompareRe = regexp.MustCompile(`^([#\w]+)\s*(=|<>|<=|>=|<|>)\s*(:\w+)$`)
+	fakeBetweenRe = regexp.MustCompile(`^([#\w]+) BETWEEN (:\w+) AND (:\w+)$`)
+	fakeBeginsRe  = regexp.MustCompile(`^begins_with\(\s*([#\w]+)\s*,\s*(:\w+)\s*\)$`)
+	fakeSectionRe = regexp.MustCompile(`\b(SET|REMOVE)\b`)
+)
+
+// eval evaluates a conjunction of simple terms against it. An empty expression holds.
+func (f *fakeDynamo) eval(expr string, req *fakeRequest, it item) (bool, *fakeError) {
+	if strings.TrimSpace(expr) == "" {
+		return true, nil
+	}
+	parts := strings.Split(expr, " AND ")
+	for i := 0; i < len(parts); i++ {
+		term := strings.TrimSpace(parts[i])
+		if strings.Contains(term, " BETWEEN ") && i+1 < len(parts) {
+			i++
+			term += " AND " + strings.TrimSpace(parts[i])
+		}
+		ok, err := f.term(term, req, it)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (f *fakeDynamo) term(term string, req *fakeRequest, it item) (bool, *fakeError) {
+	values := req.ExpressionAttributeValues
+	if m := fakeFuncRe.FindStringSubmatch(term); m != nil {
+		_, exists := it[f.name(req, m[2])]
+		return exists == (m[1] == "attribute_exists"), nil
+	}
+	if m := fakeBeginsRe.FindStringSubmatch(term); m != nil {
+		v, ok := it[f.name(req, m[1])]
+		s, _ := scalar(v)
+		prefix, _ := scalar(values[m[2]])
+		return ok && strings.HasPrefix(s, prefix), nil
+	}
+	if m := fakeBetweenRe.FindStringSubmatch(term); m != nil {
+		v := it[f.name(req, m[1])]
+		return v != nil && !less(v, values[m[2]]) && !less(values[m[3]], v), nil
+	}
+	m := fakeCompareRe.FindStringSubmatch(term)
+	if m == nil {
+		return false, &fakeError{"ValidationException", "unsupported expression " + term}
+	}
+	v, want := it[f.name(req, m[1])], values[m[3]]
+	if v == nil {
+		return m[2] == "<>", nil
+	}
+	switch m[2] {
+	case "=":
+		return reflect.DeepEqual(v, want), nil
+	case "<>":
+		return !reflect.DeepEqual(v, want), nil
+	case "<":
+		return less(v, want), nil
+	case "<=":
+		return !less(want, v), nil
+	case ">":
+		return less(want, v), nil
+	default:
+		return !less(v, want), nil
+	}
+}
+
+// update applies the SET and REMOVE clauses of req's UpdateExpression to it.
+func (f *fakeDynamo) update(req *fakeRequest, it item) *fakeError {
+	expr := req.UpdateExpression
+	idx := fakeSectionRe.FindAllStringIndex(expr, -1)
+	for n, loc := range idx {
+		end := len(expr)
+		if n+1 < len(idx) {
+			end = idx[n+1][0]
+		}
+		body := expr[loc[1]:end]
+		for _, part := range strings.Split(body, ",") {
+			part = strings.TrimSpace(part)
+			if expr[loc[0]:loc[1]] == "REMOVE" {
+				delete(it, f.name(req, part))
+				continue
+			}
+			name, value, ok := strings.Cut(part, "=")
+			v := req.ExpressionAttributeValues[strings.TrimSpace(value)]
+			if !ok || v == nil {
+				return &fakeError{"ValidationException", "unsupported update " + expr}
+			}
+			it[f.name(req, strings.TrimSpace(name))] = v
+		}
+	}
+	return nil
+}
+
+func (f *fakeDynamo) name(req *fakeRequest, n string) string {
+	if strings.HasPrefix(n, "#") {
+		return req.ExpressionAttributeNames[n]
+	}
+	return n
+}
+
+// scalar returns the string form of an S or N attribute.
+func scalar(v map[string]any) (string, bool) {
+	if s, ok := v["S"].(string); ok {
+		return s, false
+	}
+	n, _ := v["N"].(string)
+	return n, true
+}
+
+func less(a, b map[string]any) bool {
+	as, num := scalar(a)
+	bs, _ := scalar(b)
+	if num {
+		af, _ := strconv.ParseFloat(as, 64)
+		bf, _ := strconv.ParseFloat(bs, 64)
+		return af < bf
+	}
+	return as < bs
+}
+
+// wireValue converts a marshalled attribute value to the JSON the API sends.
+func wireValue(av types.AttributeValue) any {
+	switch v := av.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]any{"S": v.Value}
+	case *types.AttributeValueMemberN:
+		return map[string]any{"N": v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return map[string]any{"BOOL": v.Value}
+	case *types.AttributeValueMemberNULL:
+		return map[string]any{"NULL": true}
+	case *types.AttributeValueMemberSS:
+		return map[string]any{"SS": v.Value}
+	case *types.AttributeValueMemberNS:
+		return map[string]any{"NS": v.Value}
+	case *types.AttributeValueMemberL:
+		list := make([]any, len(v.Value))
+		for i, e := range v.Value {
+			list[i] = wireValue(e)
+		}
+		return map[string]any{"L": list}
+	case *types.AttributeValueMemberM:
+		m := map[string]any{}
+		for k, e := range v.Value {
+			m[k] = wireValue(e)
+		}
+		return map[string]any{"M": m}
+	}
+	panic(fmt.Sprintf("unsupported attribute value %T", av))
+}
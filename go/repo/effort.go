@@ -0,0 +1,96 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// stampCompletion sets CompletedAt when next has just been marked done and clears it
+// when next is open. old is nil for creations.
+func stampCompletion(old, next *models.Todo, now int64) {
+	switch {
+	case !next.Done:
+		next.CompletedAt = nil
+	case old == nil || !old.Done:
+		next.CompletedAt = &now
+	}
+}
+
+// creditPet counts a newly completed todo toward the owner's streak and gives the pet
+// the XP it and any streak milestone it reaches earn. Reopening the todo takes its
+// completion back, milestones aside. Todos created already done, as imported ones may
+// be, earn nothing.
+func creditPet(p *models.PetState, growth models.Growth, old, next *models.Todo, now time.Time) {
+	if old == nil || old.Done == next.Done {
+		return
+	}
+	if next.Done {
+		streak := p.StreakDays
+		p.RecordCompletion(now)
+		p.Gain(growth, growth.CompletionXPFor(next)+growth.StreakXP(streak, p.StreakDays))
+		return
+	}
+	if old.CompletedAt != nil {
+		p.UndoCompletion(time.UnixMilli(*old.CompletedAt))
+	}
+	p.Gain(growth, -growth.CompletionXPFor(old))
+}
+
+// EstimateReport compares estimated with tracked time, week by week, for the user's
+// estimated todos completed in the weeks (starting Monday in loc) that contain from
+// through to. Weeks without such todos are left out.
+func (r *TodoRepo) EstimateReport(ctx context.Context, userID string, from, to time.Time, loc *time.Location) ([]models.EstimateWeek, error) {
+	start := weekStart(from, loc)
+	end := weekStart(to, loc).AddDate(0, 0, 7)
+
+	done := true
+	page, err := r.ListTodosPage(ctx, userID, TodoListOptions{Done: &done})
+	if err != nil {
+		return nil, err
+	}
+
+	byWeek := map[string]*models.EstimateWeek{}
+	for _, t := range page.Todos {
+		if t.EstimateMinutes <= 0 || t.CompletedAt == nil {
+			continue
+		}
+		at := time.UnixMilli(*t.CompletedAt)
+		if at.Before(start) || !at.Before(end) {
+			continue
+		}
+
+		key := weekStart(at, loc).Format(time.DateOnly)
+		w, ok := byWeek[key]
+		if !ok {
+			w = &models.EstimateWeek{WeekStart: key}
+			byWeek[key] = w
+		}
+		w.Todos++
+		if t.TrackedMs == 0 {
+			w.Untracked++
+			continue
+		}
+		w.EstimatedMs += (time.Duration(t.EstimateMinutes) * time.Minute).Milliseconds()
+		w.TrackedMs += t.TrackedMs
+	}
+
+	weeks := make([]models.EstimateWeek, 0, len(byWeek))
+	for _, w := range byWeek {
+		if w.EstimatedMs > 0 {
+			w.Ratio = float64(w.TrackedMs) / float64(w.EstimatedMs)
+		}
+		weeks = append(weeks, *w)
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].WeekStart < weeks[j].WeekStart })
+	return weeks, nil
+}
+
+// weekStart returns midnight in loc on the Monday of t's week.
+func weekStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	offset := (int(t.Weekday()) + 6) % 7
+	return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, loc)
+}
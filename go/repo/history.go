@@ -0,0 +1,247 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// The history table is keyed by userId (partition) and historyKey (sort), where
+// historyKey is "<todoId>#<unix ms, zero padded>#<entryId>" so one todo's entries are
+// a contiguous, time-ordered range. Entries are only ever put, never updated.
+
+// Bookkeeping attributes that change on every write and would drown out real edits.
+var historyIgnoredFields = map[string]bool{
+	"userId":          true,
+	"todoId":          true,
+	"createdAt":       true,
+	"updatedAt":       true,
+	"version":         true,
+	"rankKey":         true,
+	"expiresAt":       true,
+	"completedAt":     true,
+	"trackedMs":       true,
+	"calendarEventId": true,
+	"calendarVersion": true,
+}
+
+type actorKey struct{}
+
+// WithActor records who is making the changes carried out under ctx.
+func WithActor(ctx context.Context, actorID string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actorID)
+}
+
+// actorFrom returns the actor on ctx, defaulting to the todo's owner.
+func actorFrom(ctx context.Context, ownerID string) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ownerID
+}
+
+// historyEntry describes the change from old to next, or returns nil if nothing a
+// user would care about changed. old is nil for creations.
+func historyEntry(ctx context.Context, old, next *models.Todo) (*models.TodoHistoryEntry, error) {
+	action := models.HistoryUpdated
+	switch {
+	case old == nil:
+		action = models.HistoryCreated
+	case !old.Trashed() && next.Trashed():
+		action = models.HistoryDeleted
+	case old.Trashed() && !next.Trashed():
+		action = models.HistoryRestored
+	}
+
+	var changes []models.FieldChange
+	if old != nil {
+		var err error
+		if changes, err = diffTodos(old, next); err != nil {
+			return nil, err
+		}
+		if action == models.HistoryUpdated && len(changes) == 0 {
+			return nil, nil
+		}
+	}
+
+	at := next.UpdatedAt
+	return &models.TodoHistoryEntry{
+		UserID:     next.UserID,
+		HistoryKey: fmt.Sprintf("%s#%013d#%s", next.TodoID, at, uuid.NewString()[:8]),
+		TodoID:     next.TodoID,
+		Action:     action,
+		Actor:      actorFrom(ctx, next.UserID),
+		At:         at,
+		Changes:    changes,
+		ProjectID:  next.ProjectID,
+	}, nil
+}
+
+// diffTodos compares the stored representation of two todos attribute by attribute,
+// so new model fields are tracked without touching this code.
+func diffTodos(old, next *models.Todo) ([]models.FieldChange, error) {
+	before, err := attributevalue.MarshalMap(old)
+	if err != nil {
+		return nil, err
+	}
+	after, err := attributevalue.MarshalMap(next)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]bool{}
+	for k := range before {
+		fields[k] = true
+	}
+	for k := range after {
+		fields[k] = true
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		if !historyIgnoredFields[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []models.FieldChange
+	for _, name := range names {
+		if reflect.DeepEqual(before[name], after[name]) {
+			continue
+		}
+		change := models.FieldChange{Field: name}
+		if av, ok := before[name]; ok {
+			if err := attributevalue.Unmarshal(av, &change.From); err != nil {
+				return nil, err
+			}
+		}
+		if av, ok := after[name]; ok {
+			if err := attributevalue.Unmarshal(av, &change.To); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func historyWrite(entry *models.TodoHistoryEntry) (types.WriteRequest, error) {
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return types.WriteRequest{}, err
+	}
+	return types.WriteRequest{PutRequest: &types.PutRequest{Item: item}}, nil
+}
+
+func (r *TodoRepo) recordHistory(ctx context.Context, old, next *models.Todo) error {
+	entry, err := historyEntry(ctx, old, next)
+	if err != nil || entry == nil {
+		return err
+	}
+	item, err := attributevalue.MarshalMap(entry)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           &r.historyTableName,
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(historyKey)"),
+	})
+	return err
+}
+
+// TodoHistory returns the history of todoID that userID may read, oldest first: that
+// of their own todo, of one shared with them directly, or of one whose latest entry
+// files it under a project shared with them. Access is worked out from the history and
+// the shares rather than the todo, so it keeps working once the todo is purged. It
+// returns ErrTodoNotFound if the user can see no history of the todo.
+func (r *TodoRepo) TodoHistory(ctx context.Context, userID, todoID string) ([]models.TodoHistoryEntry, error) {
+	entries, err := r.ListHistory(ctx, userID, todoID)
+	if err != nil || len(entries) > 0 {
+		return entries, err
+	}
+
+	shares, err := r.sharedWith(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(shares, func(i, j int) bool {
+		return shares[i].Kind == models.ShareTodo && shares[j].Kind != models.ShareTodo
+	})
+	for _, s := range shares {
+		if s.Kind == models.ShareTodo && s.ItemID != todoID {
+			continue
+		}
+		entries, err := r.ListHistory(ctx, s.OwnerID, todoID)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		if s.Kind == models.ShareTodo {
+			return entries, nil
+		}
+		ok, err := r.filedUnder(ctx, s.OwnerID, entries[len(entries)-1], s.ItemID)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return entries, nil
+		}
+	}
+	return nil, ErrTodoNotFound
+}
+
+// filedUnder reports whether the todo was filed under projectID as of its latest
+// history entry, falling back to the todo itself for entries written before they
+// recorded the project.
+func (r *TodoRepo) filedUnder(ctx context.Context, ownerID string, latest models.TodoHistoryEntry, projectID string) (bool, error) {
+	if latest.ProjectID != "" {
+		return latest.ProjectID == projectID, nil
+	}
+	t, err := r.getTodo(ctx, ownerID, latest.TodoID)
+	if err != nil || t == nil {
+		return false, err
+	}
+	return t.ProjectID == projectID, nil
+}
+
+// ListHistory returns a todo's history, oldest first. It keeps working after the todo
+// has been trashed or purged.
+func (r *TodoRepo) ListHistory(ctx context.Context, userID, todoID string) ([]models.TodoHistoryEntry, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              &r.historyTableName,
+		KeyConditionExpression: aws.String("userId = :uid AND begins_with(historyKey, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":    &types.AttributeValueMemberS{Value: userID},
+			":prefix": &types.AttributeValueMemberS{Value: todoID + "#"},
+		},
+	}
+
+	entries := []models.TodoHistoryEntry{}
+	for {
+		out, err := r.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		var page []models.TodoHistoryEntry
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, err
+		}
+		entries = append(entries, page...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return entries, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
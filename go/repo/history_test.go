@@ -0,0 +1,86 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+func TestTodoHistoryAfterPurge(t *testing.T) {
+	r, db := newTestRepo(t)
+	ctx := context.Background()
+
+	db.put(testProjects, models.Project{UserID: "owner", ProjectID: "p1", Name: "home"})
+	for _, todo := range []*models.Todo{
+		{UserID: "owner", TodoID: "filed", Text: "filed", ProjectID: "p1"},
+		{UserID: "owner", TodoID: "direct", Text: "direct"},
+	} {
+		if err := r.CreateTodo(ctx, todo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range []models.Share{
+		{UserID: "viewer", OwnerID: "owner", Kind: models.ShareTodo, ItemID: "direct", Role: models.RoleViewer},
+		{UserID: "member", OwnerID: "owner", Kind: models.ShareProject, ItemID: "p1", Role: models.RoleViewer},
+	} {
+		if err := r.ShareItem(ctx, &s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Purge both todos the way the table's TTL would, leaving their history behind.
+	for _, id := range []string{"filed", "direct"} {
+		_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+			TableName: &r.tableName,
+			Key: map[string]types.AttributeValue{
+				"userId": &types.AttributeValueMemberS{Value: "owner"},
+				"todoId": &types.AttributeValueMemberS{Value: id},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		userID, todoID string
+		found          bool
+	}{
+		{"owner", "filed", true},
+		{"owner", "direct", true},
+		{"viewer", "direct", true},
+		{"viewer", "filed", false},
+		{"member", "filed", true},
+		{"member", "direct", false},
+		{"stranger", "filed", false},
+	} {
+		entries, err := r.TodoHistory(ctx, tc.userID, tc.todoID)
+		switch {
+		case tc.found && err != nil:
+			t.Errorf("%s reading %s: %v", tc.userID, tc.todoID, err)
+		case tc.found && (len(entries) != 1 || entries[0].Action != models.HistoryCreated):
+			t.Errorf("%s reading %s got %+v, want the creation", tc.userID, tc.todoID, entries)
+		case !tc.found && !errors.Is(err, ErrTodoNotFound):
+			t.Errorf("%s reading %s got %v, %v, want ErrTodoNotFound", tc.userID, tc.todoID, entries, err)
+		}
+	}
+}
+
+func TestHistoryIgnoresBookkeeping(t *testing.T) {
+	old := &models.Todo{UserID: "u1", TodoID: "t1", Text: "call", Version: 1}
+	next := *old
+	next.Version, next.TrackedMs, next.CalendarEventID, next.CalendarVersion = 2, 60000, "evt1", 2
+
+	entry, err := historyEntry(context.Background(), old, &next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Errorf("recorded %+v for a timer and calendar sync", entry.Changes)
+	}
+}
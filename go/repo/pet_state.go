@@ -2,10 +2,12 @@ package repo
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
 	"github.com/juhun32/patriot25-gochi/go/models"
 )
@@ -13,15 +15,22 @@ import (
 type PetStateRepo struct {
 	client    *dynamodb.Client
 	tableName string
+	growth    models.Growth
 }
 
-func NewPetStateRepo(client *dynamodb.Client, tableName string) *PetStateRepo {
+func NewPetStateRepo(client *dynamodb.Client, tableName string, growth models.Growth) *PetStateRepo {
 	return &PetStateRepo{
 		client:    client,
 		tableName: tableName,
+		growth:    growth,
 	}
 }
 
+// Growth is the XP curve pets grow along.
+func (r *PetStateRepo) Growth() models.Growth {
+	return r.growth
+}
+
 func (r *PetStateRepo) GetPetState(ctx context.Context, userID string) (*models.PetState, error) {
 	key, err := attributevalue.MarshalMap(map[string]string{
 		"userId": userID,
@@ -46,6 +55,9 @@ func (r *PetStateRepo) GetPetState(ctx context.Context, userID string) (*models.
 	if err := attributevalue.UnmarshalMap(out.Item, &state); err != nil {
 		return nil, err
 	}
+	// Pets from before levels, or from under a different curve, get the level their XP
+	// reaches now.
+	state.Gain(r.growth, 0)
 	return &state, nil
 }
 
@@ -64,3 +76,60 @@ func (r *PetStateRepo) UpsertPetState(ctx context.Context, state *models.PetStat
 	})
 	return err
 }
+
+// HatchedPetState returns the user's pet, creating it with starting stats on first
+// access.
+func (r *PetStateRepo) HatchedPetState(ctx context.Context, userID string, now time.Time) (*models.PetState, error) {
+	state, err := r.GetPetState(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil && state.Hatched() {
+		return state, nil
+	}
+	return r.ModifyPetState(ctx, userID, now, func(*models.PetState) error { return nil })
+}
+
+// ModifyPetState applies fn to the user's pet, hatching it first if needed, and saves
+// the result. The write is guarded by the pet's version, so completion credit landing
+// in between is never lost; fn runs again on the fresh state after a conflict.
+func (r *PetStateRepo) ModifyPetState(ctx context.Context, userID string, now time.Time, fn func(*models.PetState) error) (*models.PetState, error) {
+	for attempt := 0; ; attempt++ {
+		state, err := r.GetPetState(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		if state == nil {
+			state = &models.PetState{UserID: userID}
+		}
+		if !state.Hatched() {
+			state.Hatch(now)
+			state.Gain(r.growth, 0)
+		}
+		old := state.Version
+		if err := fn(state); err != nil {
+			return nil, err
+		}
+		state.Version = old + 1
+
+		item, err := attributevalue.MarshalMap(state)
+		if err != nil {
+			return nil, err
+		}
+		cond, values := versionCondition(old)
+		_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                 &r.tableName,
+			Item:                      item,
+			ConditionExpression:       cond,
+			ExpressionAttributeValues: values,
+		})
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) && attempt+1 < maxModifyAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return state, nil
+	}
+}
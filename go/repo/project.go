@@ -0,0 +1,260 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+var (
+	ErrProjectNotFound = errors.New("project not found")
+	ErrProjectArchived = errors.New("project is archived")
+)
+
+// What DeleteProject does with the project's todos.
+const (
+	ProjectDeleteInbox   = "inbox"   // move them to the inbox
+	ProjectDeleteCascade = "cascade" // move them to the trash
+)
+
+// CreateProject stores a new project. The caller picks the IDs.
+func (r *TodoRepo) CreateProject(ctx context.Context, p *models.Project) error {
+	now := time.Now().UnixMilli()
+	p.CreatedAt = now
+	p.UpdatedAt = now
+
+	item, err := attributevalue.MarshalMap(p)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           &r.projectTableName,
+		Item:                item,
+		ConditionExpression: aws.String("attribute_not_exists(projectId)"),
+	})
+	return err
+}
+
+// GetProject returns the project, or nil if it does not exist.
+func (r *TodoRepo) GetProject(ctx context.Context, userID, projectID string) (*models.Project, error) {
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &r.projectTableName,
+		Key:       projectKey(userID, projectID),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+
+	var p models.Project
+	if err := attributevalue.UnmarshalMap(out.Item, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// ListProjects returns the user's projects by name, leaving out archived ones unless
+// includeArchived is set.
+func (r *TodoRepo) ListProjects(ctx context.Context, userID string, includeArchived bool) ([]models.Project, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              &r.projectTableName,
+		KeyConditionExpression: aws.String("userId = :uid"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userID},
+		},
+	}
+	if !includeArchived {
+		input.FilterExpression = aws.String("archived = :false")
+		input.ExpressionAttributeValues[":false"] = &types.AttributeValueMemberBOOL{Value: false}
+	}
+
+	projects := []models.Project{}
+	for {
+		out, err := r.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		var page []models.Project
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, err
+		}
+		projects = append(projects, page...)
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	sort.SliceStable(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+	return projects, nil
+}
+
+// ProjectUpdate describes a partial update; nil fields are left untouched.
+type ProjectUpdate struct {
+	Name     *string
+	Color    *string
+	Archived *bool
+}
+
+// UpdateProject applies upd and returns the stored result.
+func (r *TodoRepo) UpdateProject(ctx context.Context, userID, projectID string, upd ProjectUpdate) (*models.Project, error) {
+	p, err := r.GetProject(ctx, userID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrProjectNotFound
+	}
+
+	if upd.Name != nil {
+		p.Name = *upd.Name
+	}
+	if upd.Color != nil {
+		p.Color = *upd.Color
+	}
+	if upd.Archived != nil {
+		p.Archived = *upd.Archived
+	}
+	p.UpdatedAt = time.Now().UnixMilli()
+
+	item, err := attributevalue.MarshalMap(p)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           &r.projectTableName,
+		Item:                item,
+		ConditionExpression: aws.String("attribute_exists(projectId)"),
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return nil, ErrProjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// DeleteProject removes a project and its shares after moving its todos to the inbox
+// or, with ProjectDeleteCascade, to the trash. The project row goes last, so a failure part way
+// leaves it in place and the delete can simply be retried.
+func (r *TodoRepo) DeleteProject(ctx context.Context, userID, projectID, mode string) error {
+	p, err := r.GetProject(ctx, userID, projectID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProjectNotFound
+	}
+
+	todos, err := r.ListTodos(ctx, userID)
+	if err != nil {
+		return err
+	}
+	inbox := ""
+	var ops []BatchOp
+	for _, t := range todos {
+		if t.ProjectID != projectID {
+			continue
+		}
+		if mode == ProjectDeleteCascade {
+			ops = append(ops, BatchOp{Op: BatchDelete, TodoID: t.TodoID})
+		} else {
+			ops = append(ops, BatchOp{Op: BatchUpdate, TodoID: t.TodoID, Update: TodoUpdate{ProjectID: &inbox}})
+		}
+	}
+	for start := 0; start < len(ops); start += MaxBatchOps {
+		results, err := r.ApplyBatch(ctx, userID, ops[start:min(start+MaxBatchOps, len(ops))], false)
+		if err != nil {
+			return err
+		}
+		for _, res := range results {
+			// A todo deleted concurrently no longer needs moving.
+			if !res.OK && res.Error != ErrTodoNotFound.Error() {
+				return errors.New("failed to move todo " + res.TodoID + ": " + res.Error)
+			}
+		}
+	}
+
+	shares, err := r.ListShares(ctx, userID, models.ShareProject, projectID)
+	if err != nil {
+		return err
+	}
+	for _, s := range shares {
+		if err := r.RevokeShare(ctx, s.UserID, userID, models.ShareProject, projectID); err != nil && !errors.Is(err, ErrShareNotFound) {
+			return err
+		}
+	}
+
+	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: &r.projectTableName,
+		Key:       projectKey(userID, projectID),
+	})
+	return err
+}
+
+// ProjectOwner returns whose partition the todos userID files under projectID live
+// in: userID's own for their projects and the inbox (""), or the owner's for a project
+// shared with userID as an editor. Any other project is ErrProjectNotFound.
+func (r *TodoRepo) ProjectOwner(ctx context.Context, userID, projectID string) (string, error) {
+	if projectID == "" {
+		return userID, nil
+	}
+	p, err := r.GetProject(ctx, userID, projectID)
+	if err != nil {
+		return "", err
+	}
+	if p != nil {
+		return userID, nil
+	}
+
+	shares, err := r.sharedWith(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range shares {
+		if s.Kind == models.ShareProject && s.ItemID == projectID && models.RoleAllows(s.Role, models.RoleEditor) {
+			return s.OwnerID, nil
+		}
+	}
+	return "", ErrProjectNotFound
+}
+
+// checkProject reports whether todos can be filed under projectID. The inbox ("")
+// always can.
+func (r *TodoRepo) checkProject(ctx context.Context, userID, projectID string) error {
+	if projectID == "" {
+		return nil
+	}
+	p, err := r.GetProject(ctx, userID, projectID)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return ErrProjectNotFound
+	}
+	if p.Archived {
+		return ErrProjectArchived
+	}
+	return nil
+}
+
+func projectKey(userID, projectID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"userId":    &types.AttributeValueMemberS{Value: userID},
+		"projectId": &types.AttributeValueMemberS{Value: projectID},
+	}
+}
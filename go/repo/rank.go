@@ -0,0 +1,257 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// todoRankIndex is a GSI on the todos table with partition key userId and sort key
+// rankKey (projection ALL). rankKey is "<rank>#<todoId>": '#' sorts below every rank
+// digit, so the index orders by rank and then by todoId, which makes ties from
+// concurrent moves resolve the same way for every reader.
+const todoRankIndex = "rankKey-index"
+
+const rankDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalidMove = errors.New("move needs exactly one of before or after, naming another todo")
+
+// rankBetween returns a rank strictly between a and b, where "" means the start
+// (for a) or the end (for b) of the list. Ranks never end in '0', which guarantees
+// there is always room between two distinct ranks. This is the fractional indexing
+// scheme used by Figma and others: only the moved item is rewritten.
+func rankBetween(a, b string) string {
+	if b != "" {
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + rankBetween(tail(a, n), b[n:])
+		}
+	}
+
+	da := 0
+	if a != "" {
+		da = strings.IndexByte(rankDigits, a[0])
+	}
+	db := len(rankDigits)
+	if b != "" {
+		db = strings.IndexByte(rankDigits, b[0])
+	}
+	if db-da > 1 {
+		return string(rankDigits[(da+db+1)/2])
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+	return string(rankDigits[da]) + rankBetween(tail(a, 1), "")
+}
+
+// rankAfter returns a short rank greater than a, used for appending. Unlike
+// rankBetween(a, "") it bumps the first digit that has room, so appending many items
+// grows the key by one character every few dozen items instead of every ~6.
+func rankAfter(a string) string {
+	for i := 0; i < len(a); i++ {
+		if d := strings.IndexByte(rankDigits, a[i]); d < len(rankDigits)-1 {
+			return a[:i] + string(rankDigits[d+1])
+		}
+	}
+	return a + rankBetween("", "")
+}
+
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return rankDigits[0]
+}
+
+func tail(s string, n int) string {
+	if n >= len(s) {
+		return ""
+	}
+	return s[n:]
+}
+
+// neighbourRank returns the rank of the closest todo strictly before (forward=false)
+// or after (forward=true) rank, or "" if there is none.
+func (r *TodoRepo) neighbourRank(ctx context.Context, userID, rank string, forward bool) (string, error) {
+	cond, bound := "userId = :uid AND rankKey < :bound", rank
+	if forward {
+		// Every key sharing this exact rank is "<rank>#..." and '$' sorts just above '#'.
+		cond, bound = "userId = :uid AND rankKey > :bound", rank+"$"
+	}
+	return r.edgeRank(ctx, cond, map[string]types.AttributeValue{
+		":uid":   &types.AttributeValueMemberS{Value: userID},
+		":bound": &types.AttributeValueMemberS{Value: bound},
+	}, forward)
+}
+
+// lastRank returns the highest rank in the user's list, or "" if it is empty.
+func (r *TodoRepo) lastRank(ctx context.Context, userID string) (string, error) {
+	return r.edgeRank(ctx, "userId = :uid", map[string]types.AttributeValue{
+		":uid": &types.AttributeValueMemberS{Value: userID},
+	}, false)
+}
+
+// edgeRank reads the rank of the first index entry matching cond in the given direction.
+func (r *TodoRepo) edgeRank(ctx context.Context, cond string, values map[string]types.AttributeValue, forward bool) (string, error) {
+	out, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:                 &r.tableName,
+		IndexName:                 aws.String(todoRankIndex),
+		KeyConditionExpression:    aws.String(cond),
+		ExpressionAttributeValues: values,
+		ProjectionExpression:      aws.String("#rank"),
+		ExpressionAttributeNames:  map[string]string{"#rank": "rank"},
+		ScanIndexForward:          aws.Bool(forward),
+		Limit:                     aws.Int32(1),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(out.Items) == 0 {
+		return "", nil
+	}
+
+	var item struct {
+		Rank string `dynamodbav:"rank"`
+	}
+	if err := attributevalue.UnmarshalMap(out.Items[0], &item); err != nil {
+		return "", err
+	}
+	return item.Rank, nil
+}
+
+// MoveTodo places todoID immediately before or after another todo by giving it a rank
+// between that todo and its neighbour. Exactly one of beforeID and afterID must be set.
+// Every todo is ranked on creation; if several share the anchor's rank after concurrent
+// moves, the moved todo lands just outside that group.
+func (r *TodoRepo) MoveTodo(ctx context.Context, userID, todoID, beforeID, afterID string) (*models.Todo, error) {
+	anchorID := beforeID
+	if (beforeID == "") == (afterID == "") {
+		return nil, ErrInvalidMove
+	}
+	if afterID != "" {
+		anchorID = afterID
+	}
+	if anchorID == todoID {
+		return nil, ErrInvalidMove
+	}
+
+	anchor, err := r.GetTodo(ctx, userID, anchorID)
+	if err != nil {
+		return nil, err
+	}
+	if anchor == nil {
+		return nil, ErrTodoNotFound
+	}
+
+	var rank string
+	if beforeID != "" {
+		prev, err := r.neighbourRank(ctx, userID, anchor.Rank, false)
+		if err != nil {
+			return nil, err
+		}
+		rank = rankBetween(prev, anchor.Rank)
+	} else {
+		next, err := r.neighbourRank(ctx, userID, anchor.Rank, true)
+		if err != nil {
+			return nil, err
+		}
+		rank = rankBetween(anchor.Rank, next)
+	}
+
+	return r.modifyTodo(ctx, userID, todoID, func(t *models.Todo) error {
+		t.SetRank(rank)
+		return nil
+	})
+}
+
+// BackfillRanks ranks every todo written before manual ordering existed, so the rank
+// index that lists are served from includes it. Each user's unranked todos are
+// appended after their ranked ones, oldest first. It scans the whole table and is
+// meant to be run once, before the server starts serving ranked lists; running it
+// again only touches todos that are still unranked. Trashed todos are left out, as
+// trash takes them out of the index. It returns how many it ranked.
+func (r *TodoRepo) BackfillRanks(ctx context.Context) (int, error) {
+	input := &dynamodb.ScanInput{
+		TableName:            &r.tableName,
+		FilterExpression:     aws.String("attribute_not_exists(rankKey) AND attribute_not_exists(deletedAt)"),
+		ProjectionExpression: aws.String("userId, todoId, createdAt"),
+	}
+	byUser := map[string][]models.Todo{}
+	for {
+		out, err := r.client.Scan(ctx, input)
+		if err != nil {
+			return 0, err
+		}
+		var page []models.Todo
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return 0, err
+		}
+		for _, t := range page {
+			byUser[t.UserID] = append(byUser[t.UserID], t)
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	ranked := 0
+	for userID, todos := range byUser {
+		sort.Slice(todos, func(i, j int) bool {
+			if todos[i].CreatedAt != todos[j].CreatedAt {
+				return todos[i].CreatedAt < todos[j].CreatedAt
+			}
+			return todos[i].TodoID < todos[j].TodoID
+		})
+		rank, err := r.lastRank(ctx, userID)
+		if err != nil {
+			return ranked, err
+		}
+		for i := range todos {
+			rank = rankAfter(rank)
+			todos[i].SetRank(rank)
+			err := r.setRank(ctx, &todos[i])
+			var ccf *types.ConditionalCheckFailedException
+			if errors.As(err, &ccf) {
+				continue // ranked, trashed or deleted since the scan
+			}
+			if err != nil {
+				return ranked, err
+			}
+			ranked++
+		}
+	}
+	return ranked, nil
+}
+
+// setRank stores t's rank on a live todo that has none yet, leaving its version alone.
+func (r *TodoRepo) setRank(ctx context.Context, t *models.Todo) error {
+	key, err := todoKey(t.UserID, t.TodoID)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                &r.tableName,
+		Key:                      key,
+		UpdateExpression:         aws.String("SET #rank = :rank, rankKey = :rankKey"),
+		ConditionExpression:      aws.String("attribute_exists(todoId) AND attribute_not_exists(rankKey) AND attribute_not_exists(deletedAt)"),
+		ExpressionAttributeNames: map[string]string{"#rank": "rank"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":rank":    &types.AttributeValueMemberS{Value: t.Rank},
+			":rankKey": &types.AttributeValueMemberS{Value: t.RankKey},
+		},
+	})
+	return err
+}
@@ -0,0 +1,50 @@
+package repo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+func TestBackfillRanksSkipsTrash(t *testing.T) {
+	r, db := newTestRepo(t)
+	ctx := context.Background()
+
+	deletedAt := int64(5)
+	ranked := models.Todo{UserID: "u1", TodoID: "ranked", Text: "ranked", CreatedAt: 3, Version: 1}
+	ranked.SetRank(rankAfter(""))
+	db.put(testTodos, ranked)
+	db.put(testTodos, models.Todo{UserID: "u1", TodoID: "legacy", Text: "legacy", CreatedAt: 1, Version: 1})
+	db.put(testTodos, models.Todo{UserID: "u1", TodoID: "trashed", Text: "trashed", CreatedAt: 2, Version: 1, DeletedAt: &deletedAt})
+
+	for run, want := range []int{1, 0} {
+		n, err := r.BackfillRanks(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != want {
+			t.Errorf("run %d ranked %d todos, want %d", run+1, n, want)
+		}
+	}
+
+	todos, err := r.ListTodos(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, todo := range todos {
+		ids = append(ids, todo.TodoID)
+	}
+	if len(ids) != 2 || ids[0] != "ranked" || ids[1] != "legacy" {
+		t.Errorf("listed %v, want [ranked legacy]", ids)
+	}
+
+	trashed, err := r.getTodo(ctx, "u1", "trashed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trashed.RankKey != "" {
+		t.Errorf("trashed todo was ranked %q", trashed.RankKey)
+	}
+}
@@ -0,0 +1,71 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// spawnNextOccurrence creates the todo following a completed occurrence of a series.
+// The new TodoID is derived from the series and position, so completing the same
+// occurrence twice (or retrying after a failure) never produces duplicates.
+func (r *TodoRepo) spawnNextOccurrence(ctx context.Context, done *models.Todo) error {
+	if !done.Done || done.Trashed() || done.Recurrence == nil || done.DueAt == nil {
+		return nil
+	}
+
+	seriesID := done.SeriesID
+	if seriesID == "" {
+		seriesID = done.TodoID
+	}
+	n := done.Occurrence
+	if n == 0 {
+		n = 1
+	}
+
+	nextDue, ok := done.Recurrence.Next(time.UnixMilli(*done.DueAt), n)
+	if !ok {
+		return nil
+	}
+	dueAt := nextDue.UnixMilli()
+
+	next := &models.Todo{
+		UserID:          done.UserID,
+		TodoID:          uuid.NewSHA1(uuid.NameSpaceOID, []byte(fmt.Sprintf("%s/%d", seriesID, n+1))).String(),
+		Text:            done.Text,
+		Notes:           done.Notes,
+		DueAt:           &dueAt,
+		AutoComplete:    done.AutoComplete,
+		Priority:        done.Priority,
+		ProjectID:       done.ProjectID,
+		EstimateMinutes: done.EstimateMinutes,
+		Tags:            done.Tags,
+		Recurrence:      done.Recurrence,
+		SeriesID:        seriesID,
+		Occurrence:      n + 1,
+	}
+	// A series whose project was archived or deleted carries on in the inbox.
+	if err := r.checkProject(ctx, next.UserID, next.ProjectID); errors.Is(err, ErrProjectNotFound) || errors.Is(err, ErrProjectArchived) {
+		next.ProjectID = ""
+	} else if err != nil {
+		return err
+	}
+	// The next occurrence takes the completed one's place in the manual order.
+	next.SetRank(done.Rank)
+	for _, s := range done.Subtasks {
+		next.Subtasks = append(next.Subtasks, models.Subtask{SubtaskID: s.SubtaskID, Text: s.Text})
+	}
+
+	err := r.CreateTodo(ctx, next)
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+func TestRefileTodo(t *testing.T) {
+	r, db := newTestRepo(t)
+	ctx := context.Background()
+
+	db.put(testProjects, models.Project{UserID: "owner", ProjectID: "p1", Name: "team"})
+	if err := r.ShareItem(ctx, &models.Share{UserID: "filer", OwnerID: "owner", Kind: models.ShareProject, ItemID: "p1", Role: models.RoleEditor}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CreateTodo(ctx, &models.Todo{UserID: "filer", TodoID: "t1", Text: "plan offsite"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetCalendarEvent(ctx, "filer", "t1", "evt1", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ShareItem(ctx, &models.Share{UserID: "friend", OwnerID: "filer", Kind: models.ShareTodo, ItemID: "t1", Role: models.RoleViewer}); err != nil {
+		t.Fatal(err)
+	}
+
+	project := "p1"
+	moved, err := r.RefileTodo(ctx, "filer", "t1", "owner", TodoUpdate{ProjectID: &project})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.UserID != "owner" || moved.ProjectID != "p1" || moved.CalendarEventID != "" || moved.CalendarVersion != 0 {
+		t.Errorf("refiled to %+v, want the owner's p1 without a calendar event", moved)
+	}
+
+	if left, err := r.getTodo(ctx, "filer", "t1"); err != nil || left != nil {
+		t.Errorf("filer still has %+v, %v", left, err)
+	}
+	stored, err := r.getTodo(ctx, "owner", "t1")
+	if err != nil || stored == nil {
+		t.Fatalf("owner has %+v, %v", stored, err)
+	}
+	if stored.CalendarEventID != "" {
+		t.Errorf("stored todo still linked to %q", stored.CalendarEventID)
+	}
+
+	shares, err := r.ListShares(ctx, "filer", models.ShareTodo, "t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 0 {
+		t.Errorf("shares %+v outlived the refile", shares)
+	}
+	if _, _, err := r.TodoAccess(ctx, "friend", "t1"); !errors.Is(err, ErrTodoNotFound) {
+		t.Errorf("friend's access: %v, want ErrTodoNotFound", err)
+	}
+	if ownerID, role, err := r.TodoAccess(ctx, "filer", "t1"); err != nil || ownerID != "owner" || role != models.RoleEditor {
+		t.Errorf("filer's access: %s %s %v, want an editor of the owner's todo", ownerID, role, err)
+	}
+}
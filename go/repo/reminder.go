@@ -0,0 +1,186 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// todoDueIndex is a sparse GSI on the todos table with partition key dueShard and
+// sort key remindAt (projection ALL). Only open todos with a due time outside the
+// trash carry the two attributes, with remindAt equal to dueAt, so reminder passes
+// query a window of due times instead of scanning every todo. Todos are spread over
+// dueShards partitions by ID to keep any one of them from running hot.
+const (
+	todoDueIndex = "dueShard-remindAt-index"
+	dueShards    = 8
+)
+
+// todoItem is the stored form of t, including the attributes that place it in the
+// due index. Every write of a whole todo goes through it.
+func todoItem(t *models.Todo) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(t)
+	if err != nil {
+		return nil, err
+	}
+	if t.DueAt != nil && !t.Done && !t.Trashed() {
+		item["remindAt"] = &types.AttributeValueMemberN{Value: fmt.Sprint(*t.DueAt)}
+		item["dueShard"] = &types.AttributeValueMemberS{Value: dueShard(t.TodoID)}
+	}
+	return item, nil
+}
+
+func dueShard(todoID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(todoID))
+	return strconv.Itoa(int(h.Sum32() % dueShards))
+}
+
+// ListDueTodos returns every user's open, live todos due from after through before
+// (unix millis), from the due index.
+func (r *TodoRepo) ListDueTodos(ctx context.Context, after, before int64) ([]models.Todo, error) {
+	var due []models.Todo
+	for shard := 0; shard < dueShards; shard++ {
+		input := &dynamodb.QueryInput{
+			TableName:              &r.tableName,
+			IndexName:              aws.String(todoDueIndex),
+			KeyConditionExpression: aws.String("dueShard = :shard AND remindAt BETWEEN :after AND :before"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":shard":  &types.AttributeValueMemberS{Value: strconv.Itoa(shard)},
+				":after":  &types.AttributeValueMemberN{Value: fmt.Sprint(after)},
+				":before": &types.AttributeValueMemberN{Value: fmt.Sprint(before)},
+			},
+		}
+		for {
+			out, err := r.client.Query(ctx, input)
+			if err != nil {
+				return nil, err
+			}
+			var todos []models.Todo
+			if err := attributevalue.UnmarshalListOfMaps(out.Items, &todos); err != nil {
+				return nil, err
+			}
+			due = append(due, todos...)
+			if len(out.LastEvaluatedKey) == 0 {
+				break
+			}
+			input.ExclusiveStartKey = out.LastEvaluatedKey
+		}
+	}
+	return due, nil
+}
+
+// BackfillDueIndex adds the open, due todos written before the due index existed to
+// it. Like BackfillRanks it scans the whole table and is meant to be run once, from
+// cmd/backfill; it returns how many todos it added.
+func (r *TodoRepo) BackfillDueIndex(ctx context.Context) (int, error) {
+	input := &dynamodb.ScanInput{
+		TableName: &r.tableName,
+		FilterExpression: aws.String("attribute_exists(dueAt) AND done = :false AND " +
+			"attribute_not_exists(deletedAt) AND attribute_not_exists(remindAt)"),
+		ProjectionExpression: aws.String("userId, todoId, dueAt"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":false": &types.AttributeValueMemberBOOL{Value: false},
+		},
+	}
+
+	added := 0
+	for {
+		out, err := r.client.Scan(ctx, input)
+		if err != nil {
+			return added, err
+		}
+		var todos []models.Todo
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &todos); err != nil {
+			return added, err
+		}
+		for _, t := range todos {
+			key, err := todoKey(t.UserID, t.TodoID)
+			if err != nil {
+				return added, err
+			}
+			due := &types.AttributeValueMemberN{Value: fmt.Sprint(*t.DueAt)}
+			_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+				TableName:        &r.tableName,
+				Key:              key,
+				UpdateExpression: aws.String("SET remindAt = :due, dueShard = :shard"),
+				// Skip todos that were completed, trashed or rescheduled since the scan;
+				// their own write has indexed them correctly.
+				ConditionExpression: aws.String("dueAt = :due AND done = :false AND " +
+					"attribute_not_exists(deletedAt) AND attribute_not_exists(remindAt)"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":due":   due,
+					":shard": &types.AttributeValueMemberS{Value: dueShard(t.TodoID)},
+					":false": &types.AttributeValueMemberBOOL{Value: false},
+				},
+			})
+			var ccf *types.ConditionalCheckFailedException
+			if errors.As(err, &ccf) {
+				continue
+			}
+			if err != nil {
+				return added, err
+			}
+			added++
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			return added, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// ReminderRepo records which reminders have been sent so they are not repeated,
+// including across restarts. Rows are keyed by userId and reminderKey and expire
+// through the table's TTL on expiresAt (unix seconds).
+type ReminderRepo struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewReminderRepo(client *dynamodb.Client, tableName string) *ReminderRepo {
+	return &ReminderRepo{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// ClaimReminder marks a reminder as sent. It reports false if it already was.
+func (r *ReminderRepo) ClaimReminder(ctx context.Context, userID, key string, expiresAt int64) (bool, error) {
+	_, err := r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &r.tableName,
+		Item: map[string]types.AttributeValue{
+			"userId":      &types.AttributeValueMemberS{Value: userID},
+			"reminderKey": &types.AttributeValueMemberS{Value: key},
+			"expiresAt":   &types.AttributeValueMemberN{Value: fmt.Sprint(expiresAt)},
+		},
+		ConditionExpression: aws.String("attribute_not_exists(reminderKey)"),
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ReleaseReminder undoes a claim whose notification could not be delivered, so the
+// next pass tries again.
+func (r *ReminderRepo) ReleaseReminder(ctx context.Context, userID, key string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: &r.tableName,
+		Key: map[string]types.AttributeValue{
+			"userId":      &types.AttributeValueMemberS{Value: userID},
+			"reminderKey": &types.AttributeValueMemberS{Value: key},
+		},
+	})
+	return err
+}
@@ -0,0 +1,169 @@
+package repo
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// scoreRebuildAge is how old a pet's running score sums may get before reading the pet
+// rebuilds them from its owner's todos. It bounds the drift from pet updates that were
+// lost or landed out of order.
+const scoreRebuildAge = 24 * time.Hour
+
+// ScorePet returns the user's pet with its completion score and mood re-evaluated as
+// of now, hatching it on first access. visit, which may be nil, makes further changes
+// to the pet and reports whether it made any. The pet is only written when something
+// changed, and its todos are only listed to rebuild its score sums, so reading it
+// stays cheap.
+//
+// ScorePet and ModifyScoredPet are how the pet reaches its owner, so they deliver its
+// level-ups and evolutions: the returned pet carries the queued events, and the stored
+// one no longer does, so each event is handed out exactly once.
+func (r *TodoRepo) ScorePet(ctx context.Context, userID string, now time.Time, visit func(*models.PetState) bool) (*models.PetState, error) {
+	state, err := r.pets.HatchedPetState(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	sums, rebuilt, err := r.scoreSums(ctx, state, now)
+	if err != nil {
+		return nil, err
+	}
+	changed := state.Rescore(r.scoring, r.scoring.ScoreOf(sums)) || rebuilt || len(state.Events) > 0
+	if visit != nil && visit(state) {
+		changed = true
+	}
+	if !changed {
+		return state, nil
+	}
+	var events []models.PetEvent
+	state, err = r.pets.ModifyPetState(ctx, userID, now, func(p *models.PetState) error {
+		r.rescore(p, sums, rebuilt, now)
+		if visit != nil {
+			visit(p)
+		}
+		events = p.TakeEvents()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	state.Events = events
+	return state, nil
+}
+
+// ModifyScoredPet is PetStateRepo.ModifyPetState with the pet's completion score and
+// mood re-evaluated, and its events delivered, in the same write.
+func (r *TodoRepo) ModifyScoredPet(ctx context.Context, userID string, now time.Time, fn func(*models.PetState) error) (*models.PetState, error) {
+	state, err := r.pets.HatchedPetState(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	sums, rebuilt, err := r.scoreSums(ctx, state, now)
+	if err != nil {
+		return nil, err
+	}
+	var events []models.PetEvent
+	state, err = r.pets.ModifyPetState(ctx, userID, now, func(p *models.PetState) error {
+		r.rescore(p, sums, rebuilt, now)
+		if err := fn(p); err != nil {
+			return err
+		}
+		events = p.TakeEvents()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	state.Events = events
+	return state, nil
+}
+
+// scoreSums returns state's score sums advanced to now. It rebuilds them from the
+// user's todos, and reports that it did, when the pet has none yet or they are older
+// than scoreRebuildAge.
+func (r *TodoRepo) scoreSums(ctx context.Context, state *models.PetState, now time.Time) (models.ScoreSums, bool, error) {
+	if state.ScoreSums != nil && now.Sub(time.UnixMilli(state.ScoreSums.BuiltAt)) < scoreRebuildAge {
+		sums := *state.ScoreSums
+		sums.Pending = append([]models.PendingDue(nil), sums.Pending...)
+		r.scoring.Advance(&sums, now)
+		return sums, false, nil
+	}
+	todos, err := r.ListTodos(ctx, state.UserID)
+	if err != nil {
+		return models.ScoreSums{}, false, err
+	}
+	return r.scoring.Sums(todos, now), true, nil
+}
+
+// rescore stores sums on p if they were rebuilt, or else keeps p's own, which todo
+// writes may have moved since sums were read, and rescores p from them as of now.
+func (r *TodoRepo) rescore(p *models.PetState, sums models.ScoreSums, rebuilt bool, now time.Time) {
+	if rebuilt || p.ScoreSums == nil {
+		sums.Pending = append([]models.PendingDue(nil), sums.Pending...)
+		p.ScoreSums = &sums
+	}
+	r.scoring.Advance(p.ScoreSums, now)
+	p.Rescore(r.scoring, r.scoring.ScoreOf(*p.ScoreSums))
+}
+
+// petChange is a todo write as the owner's pet sees it. old is nil for creations.
+type petChange struct {
+	old, next *models.Todo
+}
+
+// updatePet credits the user's pet for the completions among changes and moves its
+// completion score by their todos' share, in one write. Changes that touch neither are
+// skipped, and a pet without score sums yet gets them on its next read.
+//
+// The todo writes are already committed, and replaying them would find nothing left
+// to credit, so a pet that cannot be written is logged rather than failing them: the
+// owner misses the credit, and the score catches up at the next rebuild.
+func (r *TodoRepo) updatePet(ctx context.Context, userID string, changes ...petChange) {
+	var moving []petChange
+	for _, c := range changes {
+		if movesPet(c.old, c.next) {
+			moving = append(moving, c)
+		}
+	}
+	if len(moving) == 0 {
+		return
+	}
+
+	now := time.Now()
+	growth := r.pets.Growth()
+	_, err := r.pets.ModifyPetState(ctx, userID, now, func(p *models.PetState) error {
+		for _, c := range moving {
+			creditPet(p, growth, c.old, c.next, now)
+			if p.ScoreSums != nil {
+				r.scoring.Update(p.ScoreSums, c.old, c.next, now)
+			}
+		}
+		if p.ScoreSums != nil {
+			p.Rescore(r.scoring, r.scoring.ScoreOf(*p.ScoreSums))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("updating the pet of user %s after a todo write failed: %v", userID, err)
+	}
+}
+
+// movesPet reports whether a write changed anything the pet's credit or completion
+// score is computed from.
+func movesPet(old, next *models.Todo) bool {
+	return old == nil ||
+		old.Done != next.Done ||
+		old.Trashed() != next.Trashed() ||
+		old.EstimateMinutes != next.EstimateMinutes ||
+		!equalInt64Ptr(old.DueAt, next.DueAt)
+}
+
+func equalInt64Ptr(a, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
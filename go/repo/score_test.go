@@ -0,0 +1,70 @@
+package repo
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+func TestPetEventsDeliveredOnce(t *testing.T) {
+	r, _ := newTestRepo(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Start the pet 10 XP, one completion, short of level 2.
+	_, err := r.pets.ModifyPetState(ctx, "u1", now, func(p *models.PetState) error {
+		p.Gain(r.pets.Growth(), 40)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	complete := func(todoID string) {
+		t.Helper()
+		if err := r.CreateTodo(ctx, &models.Todo{UserID: "u1", TodoID: todoID, Text: todoID}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.UpdateTodoDone(ctx, "u1", todoID, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	levelUp := func(level int) []models.PetEvent {
+		return []models.PetEvent{{Kind: models.PetEventLevelUp, Level: level, Stage: "puppy"}}
+	}
+
+	complete("t1")
+	for i, want := range [][]models.PetEvent{levelUp(2), nil} {
+		state, err := r.ScorePet(ctx, "u1", now, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(state.Events, want) {
+			t.Errorf("read %d delivered %+v, want %+v", i+1, state.Events, want)
+		}
+	}
+
+	// Seven more completions take the pet past 120 XP.
+	for _, id := range []string{"t2", "t3", "t4", "t5", "t6", "t7", "t8"} {
+		complete(id)
+	}
+	for i, want := range [][]models.PetEvent{levelUp(3), nil} {
+		state, err := r.ModifyScoredPet(ctx, "u1", now, func(*models.PetState) error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(state.Events, want) {
+			t.Errorf("write %d delivered %+v, want %+v", i+1, state.Events, want)
+		}
+	}
+
+	stored, err := r.pets.GetPetState(ctx, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Events) != 0 {
+		t.Errorf("stored pet still holds %+v", stored.Events)
+	}
+}
@@ -0,0 +1,304 @@
+package repo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// Field weights for search terms: a hit in the title counts for more than a tag, and a
+// tag for more than a word buried in the notes.
+const (
+	searchWeightText  = 3
+	searchWeightTags  = 2
+	searchWeightNotes = 1
+)
+
+// searchIndexTTL bounds how long a user's index is trusted before it is rebuilt from
+// the table, which picks up writes made by other server instances. Until then those
+// writes are missing from this instance's results, so with several instances a search
+// can be up to this stale.
+const searchIndexTTL = 10 * time.Minute
+
+// searchIndexMaxUsers caps how many users' indexes an instance keeps in memory.
+const searchIndexMaxUsers = 1000
+
+// searchIndex is an in-memory inverted index over each user's live todos. A user's
+// index is built from ListTodos on their first search and then kept current by
+// afterWrite and afterBatch, so queries never rescan the table. Indexes past their TTL
+// are dropped, and the least recently searched ones beyond maxUsers after them.
+type searchIndex struct {
+	mu       sync.Mutex
+	users    map[string]*userSearchIndex
+	maxUsers int
+}
+
+type userSearchIndex struct {
+	ready    chan struct{} // closed once the initial load finishes
+	err      error
+	loadedAt time.Time
+	usedAt   time.Time
+
+	docs  map[string]searchDoc          // by todoId
+	terms map[string]map[string]float64 // term -> todoId -> weight
+
+	// touched records todos written while the initial load was running, so the
+	// possibly older copy from ListTodos does not overwrite them.
+	touched map[string]bool
+}
+
+type searchDoc struct {
+	terms     map[string]float64
+	updatedAt int64
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{users: map[string]*userSearchIndex{}, maxUsers: searchIndexMaxUsers}
+}
+
+// SearchTodos returns the user's todos matching every word of query, best match
+// first. Each query word matches any indexed word it is a prefix of, case-insensitively,
+// in the todo's text, notes or tags. Ties in relevance go to the most recently updated.
+func (r *TodoRepo) SearchTodos(ctx context.Context, userID, query string, limit int) ([]models.Todo, error) {
+	words := tokenize(query)
+	if len(words) == 0 {
+		return []models.Todo{}, nil
+	}
+	u, err := r.searchIndexFor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	r.search.mu.Lock()
+	ids := u.match(words, time.Now())
+	r.search.mu.Unlock()
+
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	todos, err := r.batchGetTodos(ctx, userID, ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.Todo, 0, len(todos))
+	for _, t := range todos {
+		if !t.Trashed() {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// searchIndexFor returns the user's index, loading it if it is missing or stale.
+// Concurrent callers share a single load.
+func (r *TodoRepo) searchIndexFor(ctx context.Context, userID string) (*userSearchIndex, error) {
+	s := r.search
+	s.mu.Lock()
+	u, ok := s.users[userID]
+	if ok {
+		select {
+		case <-u.ready:
+			if u.err != nil || time.Since(u.loadedAt) > searchIndexTTL {
+				ok = false
+			}
+		default:
+		}
+	}
+	if !ok {
+		s.evict(time.Now())
+		u = &userSearchIndex{
+			ready:   make(chan struct{}),
+			usedAt:  time.Now(),
+			docs:    map[string]searchDoc{},
+			terms:   map[string]map[string]float64{},
+			touched: map[string]bool{},
+		}
+		s.users[userID] = u
+		s.mu.Unlock()
+
+		todos, err := r.ListTodos(ctx, userID)
+
+		s.mu.Lock()
+		if err != nil {
+			u.err = err
+			delete(s.users, userID)
+		} else {
+			for i := range todos {
+				if !u.touched[todos[i].TodoID] {
+					u.put(&todos[i])
+				}
+			}
+			u.touched = nil
+			u.loadedAt = time.Now()
+		}
+		close(u.ready)
+		s.mu.Unlock()
+		return u, err
+	}
+	u.usedAt = time.Now()
+	s.mu.Unlock()
+
+	select {
+	case <-u.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if u.err != nil {
+		return nil, u.err
+	}
+	return u, nil
+}
+
+// evict makes room for one more user's index, dropping the loaded indexes that have
+// outlived searchIndexTTL and then the least recently used ones until there is room.
+// Indexes still loading are left alone. The caller holds s.mu.
+func (s *searchIndex) evict(now time.Time) {
+	var loaded []string
+	for id, u := range s.users {
+		select {
+		case <-u.ready:
+		default:
+			continue
+		}
+		if now.Sub(u.loadedAt) > searchIndexTTL {
+			delete(s.users, id)
+			continue
+		}
+		loaded = append(loaded, id)
+	}
+	if len(s.users) < s.maxUsers {
+		return
+	}
+	sort.Slice(loaded, func(i, j int) bool {
+		return s.users[loaded[i]].usedAt.Before(s.users[loaded[j]].usedAt)
+	})
+	for _, id := range loaded {
+		if len(s.users) < s.maxUsers {
+			return
+		}
+		delete(s.users, id)
+	}
+}
+
+// indexTodo brings the user's index, if one has been built, in line with a write.
+// Trashed todos are dropped so they stop showing up in results.
+func (r *TodoRepo) indexTodo(t *models.Todo) {
+	if r.search == nil {
+		return
+	}
+	r.search.mu.Lock()
+	defer r.search.mu.Unlock()
+
+	u, ok := r.search.users[t.UserID]
+	if !ok {
+		return
+	}
+	if u.touched != nil {
+		u.touched[t.TodoID] = true
+	}
+	u.remove(t.TodoID)
+	if !t.Trashed() {
+		u.put(t)
+	}
+}
+
+func (u *userSearchIndex) put(t *models.Todo) {
+	doc := searchDoc{terms: map[string]float64{}, updatedAt: t.UpdatedAt}
+	add := func(words []string, weight float64) {
+		for _, w := range words {
+			if weight > doc.terms[w] {
+				doc.terms[w] = weight
+			}
+		}
+	}
+	add(tokenize(t.Text), searchWeightText)
+	add(tokenize(strings.Join(t.Tags, " ")), searchWeightTags)
+	add(tokenize(t.Notes), searchWeightNotes)
+
+	u.docs[t.TodoID] = doc
+	for term, weight := range doc.terms {
+		postings, ok := u.terms[term]
+		if !ok {
+			postings = map[string]float64{}
+			u.terms[term] = postings
+		}
+		postings[t.TodoID] = weight
+	}
+}
+
+func (u *userSearchIndex) remove(todoID string) {
+	doc, ok := u.docs[todoID]
+	if !ok {
+		return
+	}
+	for term := range doc.terms {
+		delete(u.terms[term], todoID)
+		if len(u.terms[term]) == 0 {
+			delete(u.terms, term)
+		}
+	}
+	delete(u.docs, todoID)
+}
+
+// match scores every todo containing all of words and returns their IDs in rank
+// order. A word scores its term's field weight, scaled down for a prefix match by how
+// much of the term it covers. Recency adds up to one point, down to half after a week.
+func (u *userSearchIndex) match(words []string, now time.Time) []string {
+	var scores map[string]float64
+	for _, word := range words {
+		best := map[string]float64{}
+		for term, postings := range u.terms {
+			if !strings.HasPrefix(term, word) {
+				continue
+			}
+			coverage := float64(len(word)) / float64(len(term))
+			for id, weight := range postings {
+				if s := weight * coverage; s > best[id] {
+					best[id] = s
+				}
+			}
+		}
+		if scores == nil {
+			scores = best
+			continue
+		}
+		for id := range scores {
+			if s, ok := best[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	const week = float64(7 * 24 * time.Hour / time.Millisecond)
+	ids := make([]string, 0, len(scores))
+	for id := range scores {
+		age := float64(now.UnixMilli()-u.docs[id].updatedAt) / week
+		scores[id] += 1 / (1 + max(age, 0))
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := ids[i], ids[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if u.docs[a].updatedAt != u.docs[b].updatedAt {
+			return u.docs[a].updatedAt > u.docs[b].updatedAt
+		}
+		return a < b
+	})
+	return ids
+}
+
+// tokenize lowercases s and splits it into words of letters and digits.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
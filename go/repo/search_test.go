@@ -0,0 +1,61 @@
+package repo
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+func TestSearchIndexEviction(t *testing.T) {
+	r, _ := newTestRepo(t)
+	ctx := context.Background()
+	r.search.maxUsers = 2
+
+	for _, userID := range []string{"u1", "u2", "u3", "u4"} {
+		if err := r.CreateTodo(ctx, &models.Todo{UserID: userID, TodoID: "t1", Text: "buy milk"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	search := func(userID string) {
+		t.Helper()
+		todos, err := r.SearchTodos(ctx, userID, "milk", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(todos) != 1 {
+			t.Errorf("%s found %d todos, want 1", userID, len(todos))
+		}
+	}
+	indexed := func() []string {
+		r.search.mu.Lock()
+		defer r.search.mu.Unlock()
+		var ids []string
+		for id := range r.search.users {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	// u1 is used again after u2, so u2 goes first.
+	search("u1")
+	search("u2")
+	search("u1")
+	search("u3")
+	if got, want := indexed(), []string{"u1", "u3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("indexed %v, want %v", got, want)
+	}
+
+	// An index past its TTL goes before any recently used one.
+	r.search.mu.Lock()
+	r.search.users["u3"].loadedAt = time.Now().Add(-searchIndexTTL - time.Minute)
+	r.search.mu.Unlock()
+	search("u4")
+	if got, want := indexed(), []string{"u1", "u4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("indexed %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,213 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// shareOwnerIndex is a GSI on the share table keyed by ownerId (partition) and
+// itemKey (sort), listing everyone the owner shared each item with.
+const shareOwnerIndex = "owner-index"
+
+// ErrShareNotFound is returned when revoking a share that does not exist.
+var ErrShareNotFound = errors.New("share not found")
+
+// ShareItem grants share.UserID share.Role on the owner's todo or project, replacing
+// any role they already had on it.
+func (r *TodoRepo) ShareItem(ctx context.Context, share *models.Share) error {
+	share.ShareKey = share.Kind + "#" + share.OwnerID + "#" + share.ItemID
+	share.ItemKey = share.Kind + "#" + share.ItemID + "#" + share.UserID
+	share.CreatedAt = time.Now().UnixMilli()
+
+	item, err := attributevalue.MarshalMap(share)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: &r.shareTableName,
+		Item:      item,
+	})
+	return err
+}
+
+// ListShares returns everyone the owner shared the item with.
+func (r *TodoRepo) ListShares(ctx context.Context, ownerID, kind, itemID string) ([]models.Share, error) {
+	return r.queryShares(ctx, &dynamodb.QueryInput{
+		TableName:              &r.shareTableName,
+		IndexName:              aws.String(shareOwnerIndex),
+		KeyConditionExpression: aws.String("ownerId = :owner AND begins_with(itemKey, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":owner":  &types.AttributeValueMemberS{Value: ownerID},
+			":prefix": &types.AttributeValueMemberS{Value: kind + "#" + itemID + "#"},
+		},
+	})
+}
+
+// RevokeShare removes userID's access to the owner's item.
+func (r *TodoRepo) RevokeShare(ctx context.Context, userID, ownerID, kind, itemID string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: &r.shareTableName,
+		Key: map[string]types.AttributeValue{
+			"userId":   &types.AttributeValueMemberS{Value: userID},
+			"shareKey": &types.AttributeValueMemberS{Value: kind + "#" + ownerID + "#" + itemID},
+		},
+		ConditionExpression: aws.String("attribute_exists(shareKey)"),
+	})
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return ErrShareNotFound
+	}
+	return err
+}
+
+// SharedBy returns who shared the item with userID, or ErrShareNotFound.
+func (r *TodoRepo) SharedBy(ctx context.Context, userID, kind, itemID string) (string, error) {
+	shares, err := r.sharedWith(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range shares {
+		if s.Kind == kind && s.ItemID == itemID {
+			return s.OwnerID, nil
+		}
+	}
+	return "", ErrShareNotFound
+}
+
+// sharedWith returns every share granted to the user.
+func (r *TodoRepo) sharedWith(ctx context.Context, userID string) ([]models.Share, error) {
+	return r.queryShares(ctx, &dynamodb.QueryInput{
+		TableName:              &r.shareTableName,
+		KeyConditionExpression: aws.String("userId = :uid"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userID},
+		},
+	})
+}
+
+func (r *TodoRepo) queryShares(ctx context.Context, input *dynamodb.QueryInput) ([]models.Share, error) {
+	shares := []models.Share{}
+	for {
+		out, err := r.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		var page []models.Share
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, err
+		}
+		shares = append(shares, page...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return shares, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+// TodoAccess works out whose todo todoID is from userID's point of view and the role
+// they hold on it: their own todo, one shared with them directly, or one filed under a
+// project shared with them. Trashed todos resolve too, so editors can restore them.
+// It returns ErrTodoNotFound if the user cannot see the todo at all.
+func (r *TodoRepo) TodoAccess(ctx context.Context, userID, todoID string) (ownerID, role string, err error) {
+	own, err := r.getTodo(ctx, userID, todoID)
+	if err != nil {
+		return "", "", err
+	}
+	if own != nil {
+		return userID, models.RoleOwner, nil
+	}
+
+	shares, err := r.sharedWith(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	for _, s := range shares {
+		if s.Kind == models.ShareTodo && s.ItemID == todoID && models.RoleAllows(s.Role, role) {
+			ownerID, role = s.OwnerID, s.Role
+		}
+	}
+	for _, s := range shares {
+		if s.Kind != models.ShareProject || (ownerID != "" && ownerID != s.OwnerID) || models.RoleAllows(role, s.Role) {
+			continue
+		}
+		t, err := r.getTodo(ctx, s.OwnerID, todoID)
+		if err != nil {
+			return "", "", err
+		}
+		if t != nil && t.ProjectID == s.ItemID {
+			ownerID, role = s.OwnerID, s.Role
+		}
+	}
+	if ownerID == "" {
+		return "", "", ErrTodoNotFound
+	}
+	return ownerID, role, nil
+}
+
+// listSharedTodos returns the live todos shared with the user that match opts, each
+// tagged with the user's role. A todo shared both directly and through its project
+// appears once, with the stronger role.
+func (r *TodoRepo) listSharedTodos(ctx context.Context, userID string, opts TodoListOptions) ([]models.Todo, error) {
+	shares, err := r.sharedWith(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var shared []models.Todo
+	seen := map[string]int{} // todoId -> index in shared
+	add := func(t models.Todo, role string) {
+		if (opts.Tag != "" && !hasTag(t, opts.Tag)) || !matchesListFilters(t, opts) {
+			return
+		}
+		if i, ok := seen[t.TodoID]; ok {
+			if !models.RoleAllows(shared[i].Role, role) {
+				shared[i].Role = role
+			}
+			return
+		}
+		t.Role = role
+		seen[t.TodoID] = len(shared)
+		shared = append(shared, t)
+	}
+
+	direct := map[string][]string{} // ownerId -> todoIds
+	roles := map[string]string{}    // todoId -> role
+	for _, s := range shares {
+		switch s.Kind {
+		case models.ShareTodo:
+			direct[s.OwnerID] = append(direct[s.OwnerID], s.ItemID)
+			roles[s.ItemID] = s.Role
+		case models.ShareProject:
+			projectOpts := opts
+			projectOpts.Project, projectOpts.Cursor, projectOpts.Limit = s.ItemID, "", 0
+			if opts.Project != "" && opts.Project != s.ItemID {
+				continue
+			}
+			page, err := r.listOwnTodos(ctx, s.OwnerID, projectOpts)
+			if err != nil {
+				return nil, err
+			}
+			for _, t := range page.Todos {
+				add(t, s.Role)
+			}
+		}
+	}
+	for ownerID, ids := range direct {
+		todos, err := r.batchGetTodos(ctx, ownerID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range todos {
+			add(t, roles[t.TodoID])
+		}
+	}
+	return shared, nil
+}
@@ -0,0 +1,81 @@
+package repo
+
+import (
+	"context"
+	"errors"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+var (
+	ErrSubtaskNotFound     = errors.New("subtask not found")
+	ErrInvalidSubtaskOrder = errors.New("subtask order must list every subtask exactly once")
+)
+
+func (r *TodoRepo) AddSubtask(ctx context.Context, userID, todoID string, sub models.Subtask) (*models.Todo, error) {
+	return r.modifyTodo(ctx, userID, todoID, func(t *models.Todo) error {
+		t.Subtasks = append(t.Subtasks, sub)
+		t.SyncAutoComplete()
+		return nil
+	})
+}
+
+func (r *TodoRepo) UpdateSubtask(ctx context.Context, userID, todoID, subtaskID string, text *string, done *bool) (*models.Todo, error) {
+	return r.modifyTodo(ctx, userID, todoID, func(t *models.Todo) error {
+		i := subtaskIndex(t, subtaskID)
+		if i < 0 {
+			return ErrSubtaskNotFound
+		}
+		if text != nil {
+			t.Subtasks[i].Text = *text
+		}
+		if done != nil {
+			t.Subtasks[i].Done = *done
+		}
+		t.SyncAutoComplete()
+		return nil
+	})
+}
+
+func (r *TodoRepo) DeleteSubtask(ctx context.Context, userID, todoID, subtaskID string) (*models.Todo, error) {
+	return r.modifyTodo(ctx, userID, todoID, func(t *models.Todo) error {
+		i := subtaskIndex(t, subtaskID)
+		if i < 0 {
+			return ErrSubtaskNotFound
+		}
+		t.Subtasks = append(t.Subtasks[:i], t.Subtasks[i+1:]...)
+		t.SyncAutoComplete()
+		return nil
+	})
+}
+
+// ReorderSubtasks rearranges the checklist to match order, which must be a permutation
+// of the existing subtask IDs.
+func (r *TodoRepo) ReorderSubtasks(ctx context.Context, userID, todoID string, order []string) (*models.Todo, error) {
+	return r.modifyTodo(ctx, userID, todoID, func(t *models.Todo) error {
+		if len(order) != len(t.Subtasks) {
+			return ErrInvalidSubtaskOrder
+		}
+		reordered := make([]models.Subtask, 0, len(order))
+		seen := make(map[string]bool, len(order))
+		for _, id := range order {
+			i := subtaskIndex(t, id)
+			if i < 0 || seen[id] {
+				return ErrInvalidSubtaskOrder
+			}
+			seen[id] = true
+			reordered = append(reordered, t.Subtasks[i])
+		}
+		t.Subtasks = reordered
+		return nil
+	})
+}
+
+func subtaskIndex(t *models.Todo, subtaskID string) int {
+	for i, s := range t.Subtasks {
+		if s.SubtaskID == subtaskID {
+			return i
+		}
+	}
+	return -1
+}
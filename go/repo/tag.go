@@ -0,0 +1,191 @@
+package repo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// The tag table is an inverted index from (userId, tag) to todo IDs, since DynamoDB
+// secondary indexes cannot key on the elements of a list attribute. Each row is
+//
+//	userId (partition) | tagKey = "<tag>#<todoId>" (sort) | tag | todoId
+//
+// The tags stored on the todo itself remain the source of truth: rows are rewritten
+// idempotently on every change, and queries re-check the fetched todo.
+type tagRow struct {
+	UserID string `dynamodbav:"userId"`
+	TagKey string `dynamodbav:"tagKey"`
+	Tag    string `dynamodbav:"tag"`
+	TodoID string `dynamodbav:"todoId"`
+}
+
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+func tagKey(tag, todoID string) string {
+	return tag + "#" + todoID
+}
+
+// syncTags brings the index in line with a todo whose tags went from old to current.
+// Current tags are always re-put so a retried write repairs a partial earlier failure.
+func (r *TodoRepo) syncTags(ctx context.Context, userID, todoID string, old, current []string) error {
+	writes, err := tagWrites(userID, todoID, old, current)
+	if err != nil {
+		return err
+	}
+	return batchWrite(ctx, r.client, r.tagTableName, writes)
+}
+
+func tagWrites(userID, todoID string, old, current []string) ([]types.WriteRequest, error) {
+	keep := map[string]bool{}
+	var writes []types.WriteRequest
+	for _, tag := range current {
+		keep[tag] = true
+		item, err := attributevalue.MarshalMap(tagRow{UserID: userID, TagKey: tagKey(tag, todoID), Tag: tag, TodoID: todoID})
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+	}
+	for _, tag := range old {
+		if keep[tag] {
+			continue
+		}
+		key, err := attributevalue.MarshalMap(map[string]string{"userId": userID, "tagKey": tagKey(tag, todoID)})
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+	}
+	return writes, nil
+}
+
+// listTodosByTag pages through the tag index and hydrates the matching todos. Other
+// filters are applied in memory with the same semantics as the main query.
+func (r *TodoRepo) listTodosByTag(ctx context.Context, userID string, opts TodoListOptions) (*TodoPage, error) {
+	startKey, err := decodeCursor(opts.Cursor, userID, "tagKey")
+	if err != nil {
+		return nil, err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              &r.tagTableName,
+		KeyConditionExpression: aws.String("userId = :uid AND begins_with(tagKey, :prefix)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":    &types.AttributeValueMemberS{Value: userID},
+			":prefix": &types.AttributeValueMemberS{Value: opts.Tag + "#"},
+		},
+	}
+
+	page := &TodoPage{Todos: []models.Todo{}}
+	for {
+		input.ExclusiveStartKey = startKey
+		if opts.Limit > 0 {
+			input.Limit = aws.Int32(opts.Limit - int32(len(page.Todos)))
+		}
+
+		out, err := r.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		var rows []tagRow
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &rows); err != nil {
+			return nil, err
+		}
+		ids := make([]string, len(rows))
+		for i, row := range rows {
+			ids[i] = row.TodoID
+		}
+		todos, err := r.batchGetTodos(ctx, userID, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range todos {
+			if hasTag(t, opts.Tag) && matchesListFilters(t, opts) {
+				page.Todos = append(page.Todos, t)
+			}
+		}
+
+		startKey = out.LastEvaluatedKey
+		if len(startKey) == 0 {
+			return page, nil
+		}
+		if opts.Limit > 0 && int32(len(page.Todos)) >= opts.Limit {
+			page.NextCursor, err = encodeCursor(startKey)
+			if err != nil {
+				return nil, err
+			}
+			return page, nil
+		}
+	}
+}
+
+// ListTagCounts returns every tag the user has applied, most used first.
+func (r *TodoRepo) ListTagCounts(ctx context.Context, userID string) ([]TagCount, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              &r.tagTableName,
+		KeyConditionExpression: aws.String("userId = :uid"),
+		ProjectionExpression:   aws.String("tag"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userID},
+		},
+	}
+
+	counts := map[string]int{}
+	for {
+		out, err := r.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		var rows []tagRow
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &rows); err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			counts[row.Tag]++
+		}
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	result := make([]TagCount, 0, len(counts))
+	for tag, n := range counts {
+		result = append(result, TagCount{Tag: tag, Count: n})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result, nil
+}
+
+// indexedTags is the set of tags a todo should have rows for; trashed todos have none.
+func indexedTags(t *models.Todo) []string {
+	if t == nil || t.Trashed() {
+		return nil
+	}
+	return t.Tags
+}
+
+func hasTag(t models.Todo, tag string) bool {
+	for _, have := range t.Tags {
+		if have == tag {
+			return true
+		}
+	}
+	return false
+}
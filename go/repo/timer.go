@@ -0,0 +1,317 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// The session table is keyed by userId (partition) and sessionKey (sort). Finished
+// sessions use "<startedAt, zero padded>#<todoId>" so a user's sessions are ordered by
+// start time. The user's single running timer lives under runningSessionKey, which
+// sorts after every finished session.
+const runningSessionKey = "running"
+
+// maxSessionLookback is how far before a summary's first day sessions are looked up,
+// so that a session that crosses midnight still counts toward the day it ran into.
+const maxSessionLookback = 24 * time.Hour
+
+// ErrNoTimerRunning is returned when stopping a timer that is not running.
+var ErrNoTimerRunning = errors.New("no timer running for this todo")
+
+// RunningTimer returns the user's running timer, or nil if none is running.
+func (r *TodoRepo) RunningTimer(ctx context.Context, userID string) (*models.TimeSession, error) {
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      &r.sessionTableName,
+		Key:            sessionKey(userID, runningSessionKey),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out.Item == nil {
+		return nil, nil
+	}
+
+	var s models.TimeSession
+	if err := attributevalue.UnmarshalMap(out.Item, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// StartTimer starts userID timing ownerID's todo, which may be their own. Whatever
+// timer the user had running is stopped first and returned as stopped; starting the
+// todo that is already being timed leaves its timer alone.
+func (r *TodoRepo) StartTimer(ctx context.Context, userID, ownerID, todoID string) (running, stopped *models.TimeSession, err error) {
+	todo, err := r.GetTodo(ctx, ownerID, todoID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if todo == nil {
+		return nil, nil, ErrTodoNotFound
+	}
+
+	for attempt := 0; ; attempt++ {
+		current, err := r.RunningTimer(ctx, userID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if current != nil && current.TodoID == todoID {
+			return current, stopped, nil
+		}
+		if current != nil {
+			s, err := r.stopTimer(ctx, current)
+			if err != nil {
+				return nil, nil, err
+			}
+			if s != nil {
+				stopped = s
+			}
+		}
+
+		running = &models.TimeSession{
+			UserID:     userID,
+			SessionKey: runningSessionKey,
+			TodoID:     todoID,
+			StartedAt:  time.Now().UnixMilli(),
+		}
+		if ownerID != userID {
+			running.OwnerID = ownerID
+		}
+		item, err := attributevalue.MarshalMap(running)
+		if err != nil {
+			return nil, nil, err
+		}
+		_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:           &r.sessionTableName,
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(sessionKey)"),
+		})
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) && attempt+1 < maxModifyAttempts {
+			// Another start won the race; stop its timer and try again.
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return running, stopped, nil
+	}
+}
+
+// StopTimer stops the user's timer if it is running for todoID and returns the
+// finished session.
+func (r *TodoRepo) StopTimer(ctx context.Context, userID, todoID string) (*models.TimeSession, error) {
+	current, err := r.RunningTimer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || current.TodoID != todoID {
+		return nil, ErrNoTimerRunning
+	}
+	s, err := r.stopTimer(ctx, current)
+	if err != nil {
+		return nil, err
+	}
+	if s == nil {
+		return nil, ErrNoTimerRunning
+	}
+	return s, nil
+}
+
+// stopTimer turns the running timer into a finished session and adds its length to
+// the todo, all in one transaction. The todo is rewritten like any other edit, guarded
+// by its version and followed by afterWrite, so its history and search entry keep up.
+// It returns nil if someone else stopped the timer first.
+func (r *TodoRepo) stopTimer(ctx context.Context, running *models.TimeSession) (*models.TimeSession, error) {
+	ownerID := running.UserID
+	if running.OwnerID != "" {
+		ownerID = running.OwnerID
+	}
+
+	for attempt := 0; ; attempt++ {
+		endedAt := time.Now().UnixMilli()
+		session := *running
+		session.EndedAt = &endedAt
+		session.SessionKey = fmt.Sprintf("%013d#%s", session.StartedAt, session.TodoID)
+
+		item, err := attributevalue.MarshalMap(session)
+		if err != nil {
+			return nil, err
+		}
+		items := []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName: &r.sessionTableName,
+				Item:      item,
+			}},
+			{Delete: &types.Delete{
+				TableName:           &r.sessionTableName,
+				Key:                 sessionKey(running.UserID, runningSessionKey),
+				ConditionExpression: aws.String("todoId = :todo AND startedAt = :started"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":todo":    &types.AttributeValueMemberS{Value: running.TodoID},
+					":started": &types.AttributeValueMemberN{Value: fmt.Sprint(running.StartedAt)},
+				},
+			}},
+		}
+
+		// The todo may have been purged from the trash while its timer ran; the
+		// session is still worth keeping.
+		old, err := r.getTodo(ctx, ownerID, running.TodoID)
+		if err != nil {
+			return nil, err
+		}
+		var next *models.Todo
+		if old != nil {
+			tracked := *old
+			tracked.TrackedMs += session.DurationMs(endedAt)
+			tracked.UpdatedAt = endedAt
+			tracked.Version = old.Version + 1
+			next = &tracked
+
+			stored, err := todoItem(next)
+			if err != nil {
+				return nil, err
+			}
+			cond, values := versionCondition(old.Version)
+			items = append(items, types.TransactWriteItem{Put: &types.Put{
+				TableName:                 &r.tableName,
+				Item:                      stored,
+				ConditionExpression:       cond,
+				ExpressionAttributeValues: values,
+			}})
+		}
+
+		_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: items})
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) {
+			reasons := canceled.CancellationReasons
+			if len(reasons) > 1 && aws.ToString(reasons[1].Code) == "ConditionalCheckFailed" {
+				return nil, nil
+			}
+			// The todo changed since it was read; try again on the fresh copy.
+			if len(reasons) > 2 && aws.ToString(reasons[2].Code) == "ConditionalCheckFailed" && attempt+1 < maxModifyAttempts {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if next != nil {
+			if err := r.afterWrite(ctx, old, next); err != nil {
+				return nil, err
+			}
+		}
+		return &session, nil
+	}
+}
+
+// DailyTimeSummary totals the user's tracked time per calendar day in loc for the days
+// from through to, inclusive. Sessions crossing midnight are split between days and a
+// running timer counts up to now. Days without tracked time are left out.
+func (r *TodoRepo) DailyTimeSummary(ctx context.Context, userID string, from, to time.Time, loc *time.Location) ([]models.DailyTime, error) {
+	start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+
+	sessions, err := r.listSessions(ctx, userID, start.Add(-maxSessionLookback), end)
+	if err != nil {
+		return nil, err
+	}
+	running, err := r.RunningTimer(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if running != nil {
+		sessions = append(sessions, *running)
+	}
+
+	now := time.Now()
+	byDate := map[string]*models.DailyTime{}
+	for _, s := range sessions {
+		sessionStart := time.UnixMilli(s.StartedAt)
+		sessionEnd := now
+		if s.EndedAt != nil {
+			sessionEnd = time.UnixMilli(*s.EndedAt)
+		}
+		if sessionStart.Before(start) {
+			sessionStart = start
+		}
+		if sessionEnd.After(end) {
+			sessionEnd = end
+		}
+
+		for sessionStart.Before(sessionEnd) {
+			day := sessionStart.In(loc)
+			nextDay := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+			until := sessionEnd
+			if nextDay.Before(until) {
+				until = nextDay
+			}
+
+			date := day.Format(time.DateOnly)
+			d, ok := byDate[date]
+			if !ok {
+				d = &models.DailyTime{Date: date, Todos: map[string]int64{}}
+				byDate[date] = d
+			}
+			ms := until.Sub(sessionStart).Milliseconds()
+			d.TotalMs += ms
+			d.Todos[s.TodoID] += ms
+			sessionStart = until
+		}
+	}
+
+	days := make([]models.DailyTime, 0, len(byDate))
+	for _, d := range byDate {
+		days = append(days, *d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	return days, nil
+}
+
+// listSessions returns the user's finished sessions that started in [from, to).
+func (r *TodoRepo) listSessions(ctx context.Context, userID string, from, to time.Time) ([]models.TimeSession, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              &r.sessionTableName,
+		KeyConditionExpression: aws.String("userId = :uid AND sessionKey BETWEEN :from AND :to"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid":  &types.AttributeValueMemberS{Value: userID},
+			":from": &types.AttributeValueMemberS{Value: fmt.Sprintf("%013d", from.UnixMilli())},
+			":to":   &types.AttributeValueMemberS{Value: fmt.Sprintf("%013d", to.UnixMilli())},
+		},
+	}
+
+	var sessions []models.TimeSession
+	for {
+		out, err := r.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		var page []models.TimeSession
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, page...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return sessions, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
+
+func sessionKey(userID, key string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"userId":     &types.AttributeValueMemberS{Value: userID},
+		"sessionKey": &types.AttributeValueMemberS{Value: key},
+	}
+}
@@ -2,7 +2,11 @@ package repo
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -13,33 +17,62 @@ import (
 	"github.com/juhun32/patriot25-gochi/go/models"
 )
 
+// ErrTodoNotFound is returned when a todo does not exist for the given user.
+var ErrTodoNotFound = errors.New("todo not found")
+
 type TodoRepo struct {
-	client    *dynamodb.Client
-	tableName string
+	client           *dynamodb.Client
+	tableName        string
+	tagTableName     string
+	historyTableName string
+	projectTableName string
+	sessionTableName string
+	shareTableName   string
+	pets             *PetStateRepo
+	scoring          models.Scoring
+	trashRetention   time.Duration
+	search           *searchIndex
 }
 
-func NewTodoRepo(client *dynamodb.Client, tableName string) *TodoRepo {
+func NewTodoRepo(client *dynamodb.Client, tableName, tagTableName, historyTableName, projectTableName, sessionTableName, shareTableName string, pets *PetStateRepo, scoring models.Scoring, trashRetention time.Duration) *TodoRepo {
 	return &TodoRepo{
-		client:    client,
-		tableName: tableName,
+		client:           client,
+		tableName:        tableName,
+		tagTableName:     tagTableName,
+		historyTableName: historyTableName,
+		projectTableName: projectTableName,
+		sessionTableName: sessionTableName,
+		shareTableName:   shareTableName,
+		pets:             pets,
+		scoring:          scoring,
+		trashRetention:   trashRetention,
+		search:           newSearchIndex(),
 	}
 }
 
-func (r *TodoRepo) CreateTodo(ctx context.Context, userID, todoID, text string, dueAt *int64) (*models.Todo, error) {
-	now := time.Now().UnixMilli()
-	todo := &models.Todo{
-		UserID:    userID,
-		TodoID:    todoID,
-		Text:      text,
-		Done:      false,
-		CreatedAt: now,
-		UpdatedAt: now,
-		DueAt:     dueAt,
+// CreateTodo stores a new todo, stamping its timestamps and appending it to the end of
+// the user's manual order unless it already has a rank. The caller picks the IDs.
+func (r *TodoRepo) CreateTodo(ctx context.Context, todo *models.Todo) error {
+	if err := r.checkProject(ctx, todo.UserID, todo.ProjectID); err != nil {
+		return err
 	}
+	if todo.Rank == "" {
+		last, err := r.lastRank(ctx, todo.UserID)
+		if err != nil {
+			return err
+		}
+		todo.SetRank(rankAfter(last))
+	}
+
+	now := time.Now().UnixMilli()
+	todo.CreatedAt = now
+	todo.UpdatedAt = now
+	todo.Version = 1
+	stampCompletion(nil, todo, now)
 
-	item, err := attributevalue.MarshalMap(todo)
+	item, err := todoItem(todo)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
@@ -48,69 +81,507 @@ func (r *TodoRepo) CreateTodo(ctx context.Context, userID, todoID, text string,
 		ConditionExpression: aws.String("attribute_not_exists(userId) AND attribute_not_exists(todoId)"),
 	})
 	if err != nil {
+		return err
+	}
+	return r.afterWrite(ctx, nil, todo)
+}
+
+// afterWrite brings everything derived from a todo in line with a write that just
+// succeeded: the search and tag indexes, the history log, the pet's streak, XP,
+// completion score and mood and, for recurring todos, the next occurrence. old is nil
+// for creations. Each step that can fail is idempotent, so retrying the original write
+// after a failure here repairs the derived state. The pet update is not, so it logs
+// its failures instead.
+func (r *TodoRepo) afterWrite(ctx context.Context, old, next *models.Todo) error {
+	r.indexTodo(next)
+	if err := r.syncTags(ctx, next.UserID, next.TodoID, indexedTags(old), indexedTags(next)); err != nil {
+		return err
+	}
+	if err := r.recordHistory(ctx, old, next); err != nil {
+		return err
+	}
+	r.updatePet(ctx, next.UserID, petChange{old, next})
+	return r.spawnNextOccurrence(ctx, next)
+}
+
+// ListTodos returns every todo for the user, following pagination to the end.
+func (r *TodoRepo) ListTodos(ctx context.Context, userID string) ([]models.Todo, error) {
+	page, err := r.ListTodosPage(ctx, userID, TodoListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Todos, nil
+}
+
+// TodoListOptions filters and pages ListTodosPage. Zero values mean "no constraint";
+// a zero Limit returns every matching todo.
+type TodoListOptions struct {
+	Limit     int32
+	Cursor    string
+	Done      *bool
+	DueBefore *int64 // exclusive, unix ms
+	DueAfter  *int64 // inclusive, unix ms
+	Tag       string // served from the tag index when set
+	Project   string // a project ID, or models.InboxProject for todos without one
+
+	// IncludeShared appends the todos other users shared with this one, after the
+	// user's own, on the last page.
+	IncludeShared bool
+}
+
+type TodoPage struct {
+	Todos      []models.Todo
+	NextCursor string
+}
+
+// ListTodosPage lists the user's todos, plus those shared with them if asked to.
+func (r *TodoRepo) ListTodosPage(ctx context.Context, userID string, opts TodoListOptions) (*TodoPage, error) {
+	page, err := r.listOwnTodos(ctx, userID, opts)
+	if err != nil || !opts.IncludeShared || page.NextCursor != "" {
+		return page, err
+	}
+	shared, err := r.listSharedTodos(ctx, userID, opts)
+	if err != nil {
+		return nil, err
+	}
+	page.Todos = append(page.Todos, shared...)
+	return page, nil
+}
+
+// listOwnTodos queries the user's todos in manual order via the rank index, applying
+// filters server-side. Because DynamoDB applies Limit before the filter, it keeps
+// querying until the page is full or the partition is exhausted so callers never see
+// short pages mid-list. Todos without a rank are not in the index; BackfillRanks gives
+// the ones from before manual ordering theirs.
+func (r *TodoRepo) listOwnTodos(ctx context.Context, userID string, opts TodoListOptions) (*TodoPage, error) {
+	if opts.Tag != "" {
+		return r.listTodosByTag(ctx, userID, opts)
+	}
+
+	startKey, err := decodeCursor(opts.Cursor, userID, "todoId", "rankKey")
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]types.AttributeValue{
+		":uid": &types.AttributeValueMemberS{Value: userID},
+	}
+	var filters []string
+	if opts.Done != nil {
+		filters = append(filters, "done = :done")
+		values[":done"] = &types.AttributeValueMemberBOOL{Value: *opts.Done}
+	}
+	if opts.DueBefore != nil {
+		filters = append(filters, "dueAt < :dueBefore")
+		values[":dueBefore"] = &types.AttributeValueMemberN{Value: fmt.Sprint(*opts.DueBefore)}
+	}
+	if opts.DueAfter != nil {
+		filters = append(filters, "dueAt >= :dueAfter")
+		values[":dueAfter"] = &types.AttributeValueMemberN{Value: fmt.Sprint(*opts.DueAfter)}
+	}
+	if opts.Project == models.InboxProject {
+		filters = append(filters, "attribute_not_exists(projectId)")
+	} else if opts.Project != "" {
+		filters = append(filters, "projectId = :project")
+		values[":project"] = &types.AttributeValueMemberS{Value: opts.Project}
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:                 &r.tableName,
+		IndexName:                 aws.String(todoRankIndex),
+		KeyConditionExpression:    aws.String("userId = :uid"),
+		ExpressionAttributeValues: values,
+	}
+	if len(filters) > 0 {
+		input.FilterExpression = aws.String(strings.Join(filters, " AND "))
+	}
+
+	page := &TodoPage{Todos: []models.Todo{}}
+	for {
+		input.ExclusiveStartKey = startKey
+		if opts.Limit > 0 {
+			input.Limit = aws.Int32(opts.Limit - int32(len(page.Todos)))
+		}
+
+		out, err := r.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		var todos []models.Todo
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &todos); err != nil {
+			return nil, err
+		}
+		page.Todos = append(page.Todos, todos...)
+
+		startKey = out.LastEvaluatedKey
+		if len(startKey) == 0 {
+			return page, nil
+		}
+		if opts.Limit > 0 && int32(len(page.Todos)) >= opts.Limit {
+			page.NextCursor, err = encodeCursor(startKey)
+			if err != nil {
+				return nil, err
+			}
+			return page, nil
+		}
+	}
+}
+
+// GetTodo returns the todo, or nil if it does not exist or is in the trash.
+func (r *TodoRepo) GetTodo(ctx context.Context, userID, todoID string) (*models.Todo, error) {
+	todo, err := r.getTodo(ctx, userID, todoID)
+	if err != nil || todo == nil || todo.Trashed() {
 		return nil, err
 	}
 	return todo, nil
 }
 
-func (r *TodoRepo) ListTodos(ctx context.Context, userID string) ([]models.Todo, error) {
-	keyCond, err := attributevalue.MarshalMap(map[string]string{
-		"userId": userID,
-	})
+func (r *TodoRepo) getTodo(ctx context.Context, userID, todoID string) (*models.Todo, error) {
+	key, err := todoKey(userID, todoID)
 	if err != nil {
 		return nil, err
 	}
 
-	out, err := r.client.Query(ctx, &dynamodb.QueryInput{
-		TableName:     &r.tableName,
-		KeyConditions: map[string]types.Condition{"userId": {ComparisonOperator: types.ComparisonOperatorEq, AttributeValueList: []types.AttributeValue{keyCond["userId"]}}},
+	out, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: &r.tableName,
+		Key:       key,
 	})
 	if err != nil {
 		return nil, err
 	}
+	if out.Item == nil {
+		return nil, nil
+	}
 
-	var todos []models.Todo
-	if err := attributevalue.UnmarshalListOfMaps(out.Items, &todos); err != nil {
+	var todo models.Todo
+	if err := attributevalue.UnmarshalMap(out.Item, &todo); err != nil {
 		return nil, err
 	}
-	return todos, nil
+	return &todo, nil
 }
 
-func (r *TodoRepo) UpdateTodoDone(ctx context.Context, userID, todoID string, done bool) error {
-	now := time.Now().UnixMilli()
+// TodoUpdate describes a partial update; nil fields are left untouched.
+type TodoUpdate struct {
+	Text            *string
+	Notes           *string
+	DueAt           *int64
+	ClearDueAt      bool
+	Done            *bool
+	AutoComplete    *bool
+	Priority        *string
+	EstimateMinutes *int      // 0 clears the estimate
+	ProjectID       *string   // "" moves the todo to the inbox
+	Tags            *[]string // already normalised; an empty slice clears all tags
+}
 
-	key, err := attributevalue.MarshalMap(map[string]string{
-		"userId": userID,
-		"todoId": todoID,
+// UpdateTodo applies upd to an existing todo and returns the stored result.
+func (r *TodoRepo) UpdateTodo(ctx context.Context, userID, todoID string, upd TodoUpdate) (*models.Todo, error) {
+	if upd.ProjectID != nil {
+		if err := r.checkProject(ctx, userID, *upd.ProjectID); err != nil {
+			return nil, err
+		}
+	}
+	return r.modifyTodo(ctx, userID, todoID, func(t *models.Todo) error {
+		upd.apply(t)
+		return nil
 	})
+}
+
+// RefileTodo applies upd, which must move the todo to a project, to userID's todo and
+// hands it over to ownerID, whose project it is. The todo keeps its ID and history of
+// versions but is appended to the end of the new owner's list. What userID attached to
+// it goes in the same write: the direct shares they granted are revoked, and the link
+// to the event on their calendar is dropped so the new owner's sync makes its own. It
+// fails with ErrPreconditionFailed if the todo changed since it was read.
+func (r *TodoRepo) RefileTodo(ctx context.Context, userID, todoID, ownerID string, upd TodoUpdate) (*models.Todo, error) {
+	if upd.ProjectID == nil {
+		return nil, ErrProjectNotFound
+	}
+	if err := r.checkProject(ctx, ownerID, *upd.ProjectID); err != nil {
+		return nil, err
+	}
+
+	old, err := r.getTodo(ctx, userID, todoID)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if old == nil || old.Trashed() {
+		return nil, ErrTodoNotFound
+	}
+	if expected, pinned := ifMatchFrom(ctx); pinned && old.Version != expected {
+		return nil, ErrPreconditionFailed
+	}
+	last, err := r.lastRank(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := r.ListShares(ctx, userID, models.ShareTodo, todoID)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName:        &r.tableName,
-		Key:              key,
-		UpdateExpression: aws.String("SET done = :d, updatedAt = :u"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":d": &types.AttributeValueMemberBOOL{Value: done},
-			":u": &types.AttributeValueMemberN{Value: fmt.Sprint(now)},
-		},
-	})
+	now := time.Now()
+	moved := *old
+	moved.Subtasks = append([]models.Subtask(nil), old.Subtasks...)
+	moved.Tags = append([]string(nil), old.Tags...)
+	upd.apply(&moved)
+	moved.UserID = ownerID
+	moved.CalendarEventID, moved.CalendarVersion = "", 0
+	moved.SetRank(rankAfter(last))
+	moved.UpdatedAt = now.UnixMilli()
+	moved.Version = old.Version + 1
+	stampCompletion(old, &moved, moved.UpdatedAt)
+
+	item, err := todoItem(&moved)
+	if err != nil {
+		return nil, err
+	}
+	key, err := todoKey(userID, todoID)
+	if err != nil {
+		return nil, err
+	}
+	cond, values := versionCondition(old.Version)
+	writes := []types.TransactWriteItem{
+		{Put: &types.Put{
+			TableName:           &r.tableName,
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(todoId)"),
+		}},
+		{Delete: &types.Delete{
+			TableName:                 &r.tableName,
+			Key:                       key,
+			ConditionExpression:       cond,
+			ExpressionAttributeValues: values,
+		}},
+	}
+	for _, s := range shares {
+		writes = append(writes, types.TransactWriteItem{Delete: &types.Delete{
+			TableName: &r.shareTableName,
+			Key: map[string]types.AttributeValue{
+				"userId":   &types.AttributeValueMemberS{Value: s.UserID},
+				"shareKey": &types.AttributeValueMemberS{Value: s.ShareKey},
+			},
+		}})
+	}
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: writes})
+	var canceled *types.TransactionCanceledException
+	if errors.As(err, &canceled) {
+		return nil, ErrPreconditionFailed
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// To the old owner the todo is gone; to the new one it is new, apart from any
+	// completion the same update made, which is credited against the trashed copy.
+	gone := *old
+	r.trash(&gone, now)
+	r.indexTodo(&gone)
+	if err := r.syncTags(ctx, userID, todoID, indexedTags(old), nil); err != nil {
+		return nil, err
+	}
+	if err := r.recordHistory(ctx, old, &gone); err != nil {
+		return nil, err
+	}
+	r.updatePet(ctx, userID, petChange{old, &gone})
+
+	r.indexTodo(&moved)
+	if err := r.syncTags(ctx, ownerID, todoID, nil, indexedTags(&moved)); err != nil {
+		return nil, err
+	}
+	if err := r.recordHistory(ctx, nil, &moved); err != nil {
+		return nil, err
+	}
+	r.updatePet(ctx, ownerID, petChange{&gone, &moved})
+	if err := r.spawnNextOccurrence(ctx, &moved); err != nil {
+		return nil, err
+	}
+	return &moved, nil
+}
+
+func (upd TodoUpdate) apply(t *models.Todo) {
+	if upd.Text != nil {
+		t.Text = *upd.Text
+	}
+	if upd.Notes != nil {
+		t.Notes = *upd.Notes
+	}
+	if upd.ClearDueAt {
+		t.DueAt = nil
+	} else if upd.DueAt != nil {
+		t.DueAt = upd.DueAt
+	}
+	if upd.Priority != nil {
+		t.Priority = *upd.Priority
+	}
+	if upd.EstimateMinutes != nil {
+		t.EstimateMinutes = *upd.EstimateMinutes
+	}
+	if upd.ProjectID != nil {
+		t.ProjectID = *upd.ProjectID
+	}
+	if upd.Tags != nil {
+		t.Tags = *upd.Tags
+	}
+	if upd.AutoComplete != nil {
+		t.AutoComplete = *upd.AutoComplete
+		t.SyncAutoComplete()
+	}
+	if upd.Done != nil {
+		t.Done = *upd.Done
+	}
+}
+
+const maxModifyAttempts = 3
+
+// modifyTodo runs a read-modify-write cycle for changes that cannot be expressed as a
+// single UpdateExpression (e.g. editing one element of a list). The write is guarded by
+// the version that was read and bumps it. If another writer got there first it is
+// retried, unless the caller pinned a version with WithIfMatch, in which case it fails
+// with ErrPreconditionFailed. Trashed todos are treated as missing.
+func (r *TodoRepo) modifyTodo(ctx context.Context, userID, todoID string, fn func(*models.Todo) error) (*models.Todo, error) {
+	return r.modify(ctx, userID, todoID, false, fn)
+}
+
+// modify is modifyTodo for todos that are (trashed=true) or are not in the trash.
+func (r *TodoRepo) modify(ctx context.Context, userID, todoID string, trashed bool, fn func(*models.Todo) error) (*models.Todo, error) {
+	expected, pinned := ifMatchFrom(ctx)
+	for attempt := 0; ; attempt++ {
+		todo, err := r.getTodo(ctx, userID, todoID)
+		if err != nil {
+			return nil, err
+		}
+		if todo == nil || todo.Trashed() != trashed {
+			return nil, ErrTodoNotFound
+		}
+
+		if pinned && todo.Version != expected {
+			return nil, ErrPreconditionFailed
+		}
+
+		old := *todo
+		old.Subtasks = append([]models.Subtask(nil), todo.Subtasks...)
+		old.Tags = append([]string(nil), todo.Tags...)
+		if err := fn(todo); err != nil {
+			return nil, err
+		}
+		todo.UpdatedAt = time.Now().UnixMilli()
+		todo.Version = old.Version + 1
+		stampCompletion(&old, todo, todo.UpdatedAt)
+
+		item, err := todoItem(todo)
+		if err != nil {
+			return nil, err
+		}
+
+		cond, values := versionCondition(old.Version)
+		_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+			TableName:                 &r.tableName,
+			Item:                      item,
+			ConditionExpression:       cond,
+			ExpressionAttributeValues: values,
+		})
+		var ccf *types.ConditionalCheckFailedException
+		if errors.As(err, &ccf) {
+			if pinned {
+				return nil, ErrPreconditionFailed
+			}
+			if attempt+1 < maxModifyAttempts {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := r.afterWrite(ctx, &old, todo); err != nil {
+			return nil, err
+		}
+		return todo, nil
+	}
+}
+
+func (r *TodoRepo) UpdateTodoDone(ctx context.Context, userID, todoID string, done bool) error {
+	_, err := r.UpdateTodo(ctx, userID, todoID, TodoUpdate{Done: &done})
 	return err
 }
 
+// DeleteTodo moves the todo to the trash. It stays restorable until the table's TTL
+// removes it after the repo's retention period.
 func (r *TodoRepo) DeleteTodo(ctx context.Context, userID, todoID string) error {
-	key, err := attributevalue.MarshalMap(map[string]string{
-		"userId": userID,
-		"todoId": todoID,
+	_, err := r.modifyTodo(ctx, userID, todoID, func(t *models.Todo) error {
+		r.trash(t, time.Now())
+		return nil
 	})
+	return err
+}
+
+// matchesListFilters applies the non-key filters of opts to a todo in memory, for
+// listings that cannot push them down into a FilterExpression.
+func matchesListFilters(t models.Todo, opts TodoListOptions) bool {
+	if t.Trashed() {
+		return false
+	}
+	if opts.Done != nil && t.Done != *opts.Done {
+		return false
+	}
+	if opts.DueBefore != nil && (t.DueAt == nil || *t.DueAt >= *opts.DueBefore) {
+		return false
+	}
+	if opts.DueAfter != nil && (t.DueAt == nil || *t.DueAt < *opts.DueAfter) {
+		return false
+	}
+	if opts.Project == models.InboxProject && t.ProjectID != "" {
+		return false
+	}
+	if opts.Project != "" && opts.Project != models.InboxProject && t.ProjectID != opts.Project {
+		return false
+	}
+	return true
+}
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor turns a LastEvaluatedKey into an opaque string for clients.
+func encodeCursor(key map[string]types.AttributeValue) (string, error) {
+	var plain map[string]string
+	if err := attributevalue.UnmarshalMap(key, &plain); err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(plain)
 	if err != nil {
-		return err
+		return "", err
 	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
 
-	_, err = r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: &r.tableName,
-		Key:       key,
+// decodeCursor reverses encodeCursor. The userId is always taken from the caller,
+// never the cursor, so a cursor cannot be replayed against another user's partition.
+func decodeCursor(cursor, userID string, keys ...string) (map[string]types.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var plain map[string]string
+	if err := json.Unmarshal(raw, &plain); err != nil || len(plain) != len(keys)+1 {
+		return nil, ErrInvalidCursor
+	}
+	for _, k := range keys {
+		if plain[k] == "" {
+			return nil, ErrInvalidCursor
+		}
+	}
+	plain["userId"] = userID
+	return attributevalue.MarshalMap(plain)
+}
+
+func todoKey(userID, todoID string) (map[string]types.AttributeValue, error) {
+	return attributevalue.MarshalMap(map[string]string{
+		"userId": userID,
+		"todoId": todoID,
 	})
-	return err
 }
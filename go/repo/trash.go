@@ -0,0 +1,84 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// trash marks t as deleted at now. Clearing RankKey drops it from the sparse rank
+// index, so trashed todos vanish from listings without an extra filter.
+func (r *TodoRepo) trash(t *models.Todo, now time.Time) {
+	deletedAt := now.UnixMilli()
+	expiresAt := now.Add(r.trashRetention).Unix()
+	t.DeletedAt = &deletedAt
+	t.ExpiresAt = &expiresAt
+	t.RankKey = ""
+}
+
+// RestoreTodo takes a todo out of the trash and puts it back at its old position. If
+// its project has since been deleted it comes back to the inbox.
+func (r *TodoRepo) RestoreTodo(ctx context.Context, userID, todoID string) (*models.Todo, error) {
+	return r.modify(ctx, userID, todoID, true, func(t *models.Todo) error {
+		if t.ExpiresAt != nil && *t.ExpiresAt <= time.Now().Unix() {
+			return ErrTodoNotFound
+		}
+		if t.ProjectID != "" {
+			p, err := r.GetProject(ctx, userID, t.ProjectID)
+			if err != nil {
+				return err
+			}
+			if p == nil {
+				t.ProjectID = ""
+			}
+		}
+		t.DeletedAt = nil
+		t.ExpiresAt = nil
+		t.SetRank(t.Rank)
+		return nil
+	})
+}
+
+// ListTrash returns the user's trashed todos, most recently deleted first. Items past
+// their expiry are skipped because DynamoDB's TTL sweep can lag by a day or more.
+func (r *TodoRepo) ListTrash(ctx context.Context, userID string) ([]models.Todo, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              &r.tableName,
+		KeyConditionExpression: aws.String("userId = :uid"),
+		FilterExpression:       aws.String("attribute_exists(deletedAt) AND expiresAt > :now"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":uid": &types.AttributeValueMemberS{Value: userID},
+			":now": &types.AttributeValueMemberN{Value: fmt.Sprint(time.Now().Unix())},
+		},
+	}
+
+	trashed := []models.Todo{}
+	for {
+		out, err := r.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		var todos []models.Todo
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &todos); err != nil {
+			return nil, err
+		}
+		trashed = append(trashed, todos...)
+		if len(out.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+
+	sort.Slice(trashed, func(i, j int) bool {
+		return *trashed[i].DeletedAt > *trashed[j].DeletedAt
+	})
+	return trashed, nil
+}
@@ -2,14 +2,27 @@ package repo
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 
 	"github.com/juhun32/patriot25-gochi/go/models"
 )
 
+// userEmailIndex is a GSI on the users table keyed by email; userFeedTokenIndex is a
+// sparse GSI keyed by feedToken.
+const (
+	userEmailIndex     = "email-index"
+	userFeedTokenIndex = "feedToken-index"
+)
+
 type UserRepo struct {
 	client    *dynamodb.Client
 	tableName string
@@ -22,25 +35,69 @@ func NewUserRepo(client *dynamodb.Client, tableName string) *UserRepo {
 	}
 }
 
+// UpsertUser saves the profile fields from a sign-in. Settings stored on the user,
+// such as reminder lead times, are left untouched, and CreatedAt is only set once.
 func (r *UserRepo) UpsertUser(ctx context.Context, user *models.User) error {
 	now := time.Now().UnixMilli()
-	if user.CreatedAt == 0 {
-		user.CreatedAt = now
-	}
 	user.UpdatedAt = now
 
-	item, err := attributevalue.MarshalMap(user)
+	out, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &r.tableName,
+		Key: map[string]types.AttributeValue{
+			"userId": &types.AttributeValueMemberS{Value: user.UserID},
+		},
+		UpdateExpression: aws.String("SET email = :email, #name = :name, picture = :picture, " +
+			"updatedAt = :now, createdAt = if_not_exists(createdAt, :now)"),
+		ExpressionAttributeNames: map[string]string{"#name": "name"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":email":   &types.AttributeValueMemberS{Value: user.Email},
+			":name":    &types.AttributeValueMemberS{Value: user.Name},
+			":picture": &types.AttributeValueMemberS{Value: user.Picture},
+			":now":     &types.AttributeValueMemberN{Value: strconv.FormatInt(now, 10)},
+		},
+		ReturnValues: types.ReturnValueAllNew,
+	})
 	if err != nil {
 		return err
 	}
+	return attributevalue.UnmarshalMap(out.Attributes, user)
+}
 
-	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+// SetReminderLeads replaces the user's reminder lead times; an empty list restores
+// the default.
+func (r *UserRepo) SetReminderLeads(ctx context.Context, userID string, minutes []int) (*models.User, error) {
+	input := &dynamodb.UpdateItemInput{
 		TableName: &r.tableName,
-		Item:      item,
-		// You *could* add a ConditionExpression if you want
-		// to distinguish between create/update, but not required
-	})
-	return err
+		Key: map[string]types.AttributeValue{
+			"userId": &types.AttributeValueMemberS{Value: userID},
+		},
+		ConditionExpression: aws.String("attribute_exists(userId)"),
+		ReturnValues:        types.ReturnValueAllNew,
+	}
+	if len(minutes) == 0 {
+		input.UpdateExpression = aws.String("REMOVE reminderLeadMinutes")
+	} else {
+		leads, err := attributevalue.Marshal(minutes)
+		if err != nil {
+			return nil, err
+		}
+		input.UpdateExpression = aws.String("SET reminderLeadMinutes = :leads")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{":leads": leads}
+	}
+
+	out, err := r.client.UpdateItem(ctx, input)
+	var ccf *types.ConditionalCheckFailedException
+	if errors.As(err, &ccf) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := attributevalue.UnmarshalMap(out.Attributes, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
 func (r *UserRepo) GetUserByID(ctx context.Context, userID string) (*models.User, error) {
@@ -68,3 +125,147 @@ func (r *UserRepo) GetUserByID(ctx context.Context, userID string) (*models.User
 	}
 	return &user, nil
 }
+
+// GetUserByEmail returns the user who signed in with email, or nil if there is none.
+func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	out, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              &r.tableName,
+		IndexName:              aws.String(userEmailIndex),
+		KeyConditionExpression: aws.String("email = :email"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":email": &types.AttributeValueMemberS{Value: email},
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Items) == 0 {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := attributevalue.UnmarshalMap(out.Items[0], &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RotateFeedToken gives the user a new calendar feed token, invalidating the old one.
+func (r *UserRepo) RotateFeedToken(ctx context.Context, userID string) (string, error) {
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &r.tableName,
+		Key: map[string]types.AttributeValue{
+			"userId": &types.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression:    aws.String("SET feedToken = :token"),
+		ConditionExpression: aws.String("attribute_exists(userId)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":token": &types.AttributeValueMemberS{Value: token},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// RevokeFeedToken turns the user's calendar feed off.
+func (r *UserRepo) RevokeFeedToken(ctx context.Context, userID string) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: &r.tableName,
+		Key: map[string]types.AttributeValue{
+			"userId": &types.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression: aws.String("REMOVE feedToken"),
+	})
+	return err
+}
+
+// GetUserByFeedToken returns the user whose calendar feed token this is, or nil.
+func (r *UserRepo) GetUserByFeedToken(ctx context.Context, token string) (*models.User, error) {
+	out, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              &r.tableName,
+		IndexName:              aws.String(userFeedTokenIndex),
+		KeyConditionExpression: aws.String("feedToken = :token"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":token": &types.AttributeValueMemberS{Value: token},
+		},
+		Limit: aws.Int32(1),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(out.Items) == 0 {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := attributevalue.UnmarshalMap(out.Items[0], &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetGoogleRefreshToken stores the token calendar sync uses to act for the user; an
+// empty token removes it, which also stops their sync.
+func (r *UserRepo) SetGoogleRefreshToken(ctx context.Context, userID, token string) error {
+	return r.setOrRemove(ctx, userID, "googleRefreshToken", token)
+}
+
+// SetCalendarSyncToken records where calendar sync left off; an empty token makes the
+// next pass a full sync.
+func (r *UserRepo) SetCalendarSyncToken(ctx context.Context, userID, token string) error {
+	return r.setOrRemove(ctx, userID, "calendarSyncToken", token)
+}
+
+func (r *UserRepo) setOrRemove(ctx context.Context, userID, attr, value string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName: &r.tableName,
+		Key: map[string]types.AttributeValue{
+			"userId": &types.AttributeValueMemberS{Value: userID},
+		},
+		UpdateExpression: aws.String("REMOVE " + attr),
+	}
+	if value != "" {
+		input.UpdateExpression = aws.String("SET " + attr + " = :value")
+		input.ConditionExpression = aws.String("attribute_exists(userId)")
+		input.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":value": &types.AttributeValueMemberS{Value: value},
+		}
+	}
+	_, err := r.client.UpdateItem(ctx, input)
+	return err
+}
+
+// ListCalendarUsers returns every user with calendar sync set up. It scans the whole
+// table, so it is meant for background jobs only.
+func (r *UserRepo) ListCalendarUsers(ctx context.Context) ([]models.User, error) {
+	input := &dynamodb.ScanInput{
+		TableName:        &r.tableName,
+		FilterExpression: aws.String("attribute_exists(googleRefreshToken)"),
+	}
+
+	var users []models.User
+	for {
+		out, err := r.client.Scan(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		var page []models.User
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &page); err != nil {
+			return nil, err
+		}
+		users = append(users, page...)
+		if len(out.LastEvaluatedKey) == 0 {
+			return users, nil
+		}
+		input.ExclusiveStartKey = out.LastEvaluatedKey
+	}
+}
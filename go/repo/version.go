@@ -0,0 +1,37 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrPreconditionFailed is returned when a write names a version that is no longer current.
+var ErrPreconditionFailed = errors.New("todo has been modified")
+
+type ifMatchKey struct{}
+
+// WithIfMatch makes writes under ctx apply only if the todo is still at version.
+// Without it, conflicting writes are retried against the latest version instead.
+func WithIfMatch(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, ifMatchKey{}, version)
+}
+
+func ifMatchFrom(ctx context.Context) (int64, bool) {
+	v, ok := ctx.Value(ifMatchKey{}).(int64)
+	return v, ok
+}
+
+// versionCondition guards a put on the item still being at version; items written
+// before versions existed have no attribute and count as version 0.
+func versionCondition(version int64) (*string, map[string]types.AttributeValue) {
+	if version == 0 {
+		return aws.String("attribute_not_exists(version)"), nil
+	}
+	return aws.String("version = :v"), map[string]types.AttributeValue{
+		":v": &types.AttributeValueMemberN{Value: fmt.Sprint(version)},
+	}
+}
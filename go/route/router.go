@@ -8,7 +8,7 @@ import (
 	"github.com/juhun32/patriot25-gochi/go/middleware"
 )
 
-func NewRouter(authHandler *api.AuthHandler, todosHandler *handlers.TodosHandler, userHandler *handlers.UserHandler, jwtSecret string) http.Handler {
+func NewRouter(authHandler *api.AuthHandler, todosHandler *handlers.TodosHandler, userHandler *handlers.UserHandler, petHandler *handlers.PetHandler, jwtSecret string) http.Handler {
 	mux := http.NewServeMux()
 
 	// Auth routes
@@ -28,10 +28,288 @@ func NewRouter(authHandler *api.AuthHandler, todosHandler *handlers.TodosHandler
 		}
 	})
 
-	protected := middleware.AuthMiddleware(jwtSecret, todosMux)
+	todosMux.HandleFunc("/api/todos/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			todosHandler.GetTodo(w, r)
+		case http.MethodPatch:
+			todosHandler.UpdateTodo(w, r)
+		case http.MethodDelete:
+			todosHandler.DeleteTodo(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	todosMux.HandleFunc("/api/todos/parse", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.ParseQuickAdd(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/batch", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.BatchTodos(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.SearchTodos(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/trash", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.ListTrash(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/{id}/restore", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.RestoreTodo(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/{id}/history", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.GetTodoHistory(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/{id}/move", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.MoveTodo(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/{id}/subtasks", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.AddSubtask(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/{id}/subtasks/order", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.ReorderSubtasks(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/{id}/subtasks/{subtaskId}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			todosHandler.UpdateSubtask(w, r)
+		case http.MethodDelete:
+			todosHandler.DeleteSubtask(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	todosMux.HandleFunc("/api/todos/{id}/shares", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			todosHandler.ListTodoShares(w, r)
+		case http.MethodPost:
+			todosHandler.ShareTodo(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	todosMux.HandleFunc("/api/todos/{id}/shares/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.UnshareTodo(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/{id}/timer/start", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.StartTimer(w, r)
+	})
+	todosMux.HandleFunc("/api/todos/{id}/timer/stop", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.StopTimer(w, r)
+	})
+	todosMux.HandleFunc("/api/projects/{id}/shares", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			todosHandler.ListProjectShares(w, r)
+		case http.MethodPost:
+			todosHandler.ShareProject(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	todosMux.HandleFunc("/api/projects/{id}/shares/{userId}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.UnshareProject(w, r)
+	})
+	todosMux.HandleFunc("/api/timer", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.GetTimer(w, r)
+	})
+	todosMux.HandleFunc("/api/time/summary", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.TimeSummary(w, r)
+	})
+	todosMux.HandleFunc("/api/estimates", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.EstimateReport(w, r)
+	})
+
+	todosMux.HandleFunc("/api/export", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.ExportTodos(w, r)
+	})
+	todosMux.HandleFunc("/api/import", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.ImportTodos(w, r)
+	})
+
+	todosMux.HandleFunc("/api/tags", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.ListTags(w, r)
+	})
+
+	todosMux.HandleFunc("/api/projects", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			todosHandler.ListProjects(w, r)
+		case http.MethodPost:
+			todosHandler.CreateProject(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	todosMux.HandleFunc("/api/projects/{id}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			todosHandler.GetProject(w, r)
+		case http.MethodPatch:
+			todosHandler.UpdateProject(w, r)
+		case http.MethodDelete:
+			todosHandler.DeleteProject(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+
+	protected := middleware.AuthMiddleware(jwtSecret, middleware.Preconditions(todosMux))
 	mux.Handle("/api/todos", protected)
+	mux.Handle("/api/todos/", protected)
+	mux.Handle("/api/tags", protected)
+	mux.Handle("/api/projects", protected)
+	mux.Handle("/api/projects/", protected)
+	mux.Handle("/api/timer", protected)
+	mux.Handle("/api/time/summary", protected)
+	mux.Handle("/api/estimates", protected)
+	mux.Handle("/api/export", protected)
+	mux.Handle("/api/import", protected)
 
-	mux.Handle("/user", middleware.AuthMiddleware(jwtSecret, http.HandlerFunc(userHandler.GetUser)))
+	mux.Handle("/user", middleware.AuthMiddleware(jwtSecret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			userHandler.GetUser(w, r)
+		case http.MethodPatch:
+			userHandler.UpdateUser(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})))
+
+	mux.Handle("/user/feed", middleware.AuthMiddleware(jwtSecret, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			userHandler.GetFeed(w, r)
+		case http.MethodPost:
+			userHandler.RotateFeed(w, r)
+		case http.MethodDelete:
+			userHandler.DeleteFeed(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})))
+
+	petMux := http.NewServeMux()
+	petMux.HandleFunc("/api/pet", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			petHandler.GetPet(w, r)
+		case http.MethodPatch:
+			petHandler.UpdatePet(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	petMux.HandleFunc("/api/pet/feed", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		petHandler.FeedPet(w, r)
+	})
+	petMux.HandleFunc("/api/pet/treat", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		petHandler.GiveTreat(w, r)
+	})
+	petMux.HandleFunc("/api/pet/sleep", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		petHandler.PutPetToSleep(w, r)
+	})
+	mux.Handle("/api/pet", middleware.AuthMiddleware(jwtSecret, petMux))
+	mux.Handle("/api/pet/", middleware.AuthMiddleware(jwtSecret, petMux))
+
+	// Calendar feeds authenticate with the token in the URL, not the session cookie.
+	mux.HandleFunc("/feeds/{file}", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		todosHandler.CalendarFeed(w, r)
+	})
 
 	// apply CORS middleware for a specific origin and return wrapped mux
 	return middleware.CORS("http://localhost:3000")(mux)
@@ -0,0 +1,158 @@
+package transfer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var csvHeader = []string{"text", "done", "due", "priority", "tags", "notes", "estimate"}
+
+// csvEncoder writes a header row and then one row per record. Tags are space
+// separated, since tags cannot contain spaces, and estimates are in minutes, empty
+// for none.
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(csvHeader)
+}
+
+func (e *csvEncoder) Encode(rec Record) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	due := ""
+	if rec.DueAt != nil {
+		due = formatDue(*rec.DueAt)
+	}
+	estimate := ""
+	if rec.EstimateMinutes != 0 {
+		estimate = strconv.Itoa(rec.EstimateMinutes)
+	}
+	return e.w.Write([]string{
+		rec.Text,
+		strconv.FormatBool(rec.Done),
+		due,
+		rec.Priority,
+		strings.Join(rec.Tags, " "),
+		rec.Notes,
+		estimate,
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// decodeCSV reads rows by the column names in the header row, so columns may come in
+// any order and unknown ones are ignored. Only text is required.
+func decodeCSV(r io.Reader, loc *time.Location, fn RecordFunc) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		switch name {
+		case "dueat":
+			name = "due"
+		case "estimateminutes":
+			name = "estimate"
+		}
+		cols[name] = i
+	}
+	if _, ok := cols["text"]; !ok {
+		return errors.New("CSV header needs a text column")
+	}
+
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			if err := fn(parseErr.Line, Record{}, parseErr.Err); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		line, _ := cr.FieldPos(0)
+		rec, err := csvRecord(row, cols, loc)
+		if err := fn(line, rec, err); err != nil {
+			return err
+		}
+	}
+}
+
+func csvRecord(row []string, cols map[string]int, loc *time.Location) (Record, error) {
+	field := func(name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	rec := Record{
+		Text:     field("text"),
+		Notes:    field("notes"),
+		Priority: strings.ToLower(field("priority")),
+	}
+	if v := field("tags"); v != "" {
+		rec.Tags = strings.FieldsFunc(v, func(r rune) bool {
+			return r == ' ' || r == ',' || r == ';'
+		})
+	}
+	if v := field("done"); v != "" {
+		switch strings.ToLower(v) {
+		case "true", "1", "yes", "y", "x", "done":
+			rec.Done = true
+		case "false", "0", "no", "n", "":
+		default:
+			return rec, fmt.Errorf("invalid done value %q", v)
+		}
+	}
+	if v := field("estimate"); v != "" {
+		minutes, err := strconv.Atoi(v)
+		if err != nil {
+			return rec, fmt.Errorf("invalid estimate %q", v)
+		}
+		rec.EstimateMinutes = minutes
+	}
+	due, err := parseDue(field("due"), loc)
+	if err != nil {
+		return rec, err
+	}
+	rec.DueAt = due
+	return rec, nil
+}
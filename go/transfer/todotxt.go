@@ -0,0 +1,147 @@
+package transfer
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+// todo.txt priorities are letters; A to C map onto ours and anything lower reads as low.
+var (
+	todoTxtPriorities = map[string]string{
+		models.PriorityHigh:   "A",
+		models.PriorityMedium: "B",
+		models.PriorityLow:    "C",
+	}
+	todoTxtPriorityRe = regexp.MustCompile(`^\(([A-Z])\)$`)
+	todoTxtDateRe     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// todoTxtEncoder writes one todo.txt line per record:
+//
+//	x (done) | (A) priority, then text, +tag for each tag and due:<RFC 3339>
+//
+// Done todos carry their priority as pri:A, as the format suggests. Words of the text
+// that would read back as something else (a leading x, priority or date, a +tag or
+// @context, due: or pri:) are escaped with a backslash, as in quick-add. Words are
+// separated by single spaces, so runs of whitespace in the text, line breaks
+// included, do not survive a round trip.
+type todoTxtEncoder struct {
+	w io.Writer
+}
+
+func (e *todoTxtEncoder) Encode(rec Record) error {
+	var parts []string
+	pri := todoTxtPriorities[rec.Priority]
+	if rec.Done {
+		parts = append(parts, "x")
+	} else if pri != "" {
+		parts = append(parts, "("+pri+")")
+	}
+	parts = append(parts, todoTxtEscape(strings.Fields(rec.Text))...)
+	for _, tag := range rec.Tags {
+		parts = append(parts, "+"+tag)
+	}
+	if rec.DueAt != nil {
+		parts = append(parts, "due:"+formatDue(*rec.DueAt))
+	}
+	if rec.Done && pri != "" {
+		parts = append(parts, "pri:"+pri)
+	}
+	_, err := io.WriteString(e.w, strings.Join(parts, " ")+"\n")
+	return err
+}
+
+// todoTxtEscape puts a backslash in front of the text words todoTxtRecord would not
+// keep as they are, and of words that already start with one.
+func todoTxtEscape(words []string) []string {
+	out := make([]string, len(words))
+	for i, w := range words {
+		if (i == 0 && (w == "x" || todoTxtPriorityRe.MatchString(w) || todoTxtDateRe.MatchString(w))) ||
+			(len(w) > 1 && (w[0] == '+' || w[0] == '@')) ||
+			strings.HasPrefix(w, `\`) || strings.HasPrefix(w, "due:") || strings.HasPrefix(w, "pri:") {
+			w = `\` + w
+		}
+		out[i] = w
+	}
+	return out
+}
+
+func (e *todoTxtEncoder) Close() error {
+	return nil
+}
+
+func decodeTodoTxt(r io.Reader, loc *time.Location, fn RecordFunc) error {
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		rec, err := todoTxtRecord(text, loc)
+		if err := fn(line, rec, err); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// todoTxtRecord parses one line. +project and @context words become tags; due: and
+// pri: are read; every other word stays in the text, less one leading backslash.
+func todoTxtRecord(line string, loc *time.Location) (Record, error) {
+	var rec Record
+	words := strings.Fields(line)
+
+	if len(words) > 0 && words[0] == "x" {
+		rec.Done = true
+		words = words[1:]
+	} else if len(words) > 0 {
+		if m := todoTxtPriorityRe.FindStringSubmatch(words[0]); m != nil {
+			rec.Priority = todoTxtPriority(m[1])
+			words = words[1:]
+		}
+	}
+	// Completion and creation dates.
+	for i := 0; i < 2 && len(words) > 0 && todoTxtDateRe.MatchString(words[0]); i++ {
+		words = words[1:]
+	}
+
+	var text []string
+	for _, w := range words {
+		switch {
+		case len(w) > 1 && w[0] == '\\':
+			text = append(text, w[1:])
+		case len(w) > 1 && (w[0] == '+' || w[0] == '@'):
+			rec.Tags = append(rec.Tags, w[1:])
+		case strings.HasPrefix(w, "due:"):
+			due, err := parseDue(strings.TrimPrefix(w, "due:"), loc)
+			if err != nil {
+				return rec, err
+			}
+			rec.DueAt = due
+		case strings.HasPrefix(w, "pri:") && len(w) == 5:
+			rec.Priority = todoTxtPriority(w[4:])
+		default:
+			text = append(text, w)
+		}
+	}
+	rec.Text = strings.Join(text, " ")
+	if rec.Text == "" {
+		return rec, fmt.Errorf("line has no text")
+	}
+	return rec, nil
+}
+
+func todoTxtPriority(letter string) string {
+	for pri, l := range todoTxtPriorities {
+		if l == letter {
+			return pri
+		}
+	}
+	return models.PriorityLow
+}
@@ -0,0 +1,148 @@
+// Package transfer reads and writes todos in portable formats: a JSON array, CSV with
+// a header row, and todo.txt. Each format keeps a todo's text, done state, due time,
+// tags and priority; JSON and CSV keep notes and time estimates as well.
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+const (
+	FormatJSON    = "json"
+	FormatCSV     = "csv"
+	FormatTodoTxt = "todotxt"
+)
+
+// ErrUnknownFormat is returned for a format other than the ones above.
+var ErrUnknownFormat = errors.New("format must be json, csv or todotxt")
+
+// Due dates given without a time of day mean the end of that day, as in quick-add.
+const (
+	defaultHour   = 23
+	defaultMinute = 59
+)
+
+// Record is one todo as it travels between systems.
+type Record struct {
+	Text     string   `json:"text"`
+	Notes    string   `json:"notes,omitempty"`
+	Done     bool     `json:"done"`
+	DueAt    *int64   `json:"dueAt,omitempty"` // unix ms
+	Priority string   `json:"priority,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+
+	EstimateMinutes int `json:"estimateMinutes,omitempty"`
+}
+
+// FromTodo keeps the portable parts of t.
+func FromTodo(t models.Todo) Record {
+	return Record{
+		Text:     t.Text,
+		Notes:    t.Notes,
+		Done:     t.Done,
+		DueAt:    t.DueAt,
+		Priority: t.Priority,
+		Tags:     t.Tags,
+
+		EstimateMinutes: t.EstimateMinutes,
+	}
+}
+
+// Todo validates the record and turns it into a todo for userID, without IDs.
+func (rec Record) Todo(userID string) (*models.Todo, error) {
+	text := strings.TrimSpace(rec.Text)
+	if text == "" {
+		return nil, errors.New("text is required")
+	}
+	if !models.ValidPriority(rec.Priority) {
+		return nil, fmt.Errorf("invalid priority %q", rec.Priority)
+	}
+	if err := models.ValidateEstimate(rec.EstimateMinutes); err != nil {
+		return nil, err
+	}
+	tags, err := models.NormalizeTags(rec.Tags)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Todo{
+		UserID:          userID,
+		Text:            text,
+		Notes:           rec.Notes,
+		Done:            rec.Done,
+		DueAt:           rec.DueAt,
+		Priority:        rec.Priority,
+		Tags:            tags,
+		EstimateMinutes: rec.EstimateMinutes,
+	}, nil
+}
+
+// Encoder writes records one at a time; Close finishes the document.
+type Encoder interface {
+	Encode(rec Record) error
+	Close() error
+}
+
+// NewEncoder returns an encoder for format writing to w.
+func NewEncoder(w io.Writer, format string) (Encoder, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonEncoder{w: w}, nil
+	case FormatCSV:
+		return newCSVEncoder(w), nil
+	case FormatTodoTxt:
+		return &todoTxtEncoder{w: w}, nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// RecordFunc receives each record read, or the error that stopped it from being read,
+// with the line it came from: the 1-based file line for CSV and todo.txt and the
+// 1-based array position for JSON. Returning an error stops decoding.
+type RecordFunc func(line int, rec Record, err error) error
+
+// Decode reads every record in r. Date-only due dates are read in loc. Problems with
+// a single record are passed to fn; Decode itself only fails when the input as a whole
+// cannot be read.
+func Decode(r io.Reader, format string, loc *time.Location, fn RecordFunc) error {
+	switch format {
+	case FormatJSON:
+		return decodeJSON(r, fn)
+	case FormatCSV:
+		return decodeCSV(r, loc, fn)
+	case FormatTodoTxt:
+		return decodeTodoTxt(r, loc, fn)
+	}
+	return ErrUnknownFormat
+}
+
+// formatDue writes a due time losslessly, in UTC.
+func formatDue(ms int64) string {
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)
+}
+
+// parseDue accepts RFC 3339 timestamps, YYYY-MM-DD dates (end of day in loc) and unix
+// milliseconds.
+func parseDue(s string, loc *time.Location) (*int64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var due time.Time
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		due = t
+	} else if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		due = time.Date(t.Year(), t.Month(), t.Day(), defaultHour, defaultMinute, 0, 0, loc)
+	} else if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return &ms, nil
+	} else {
+		return nil, fmt.Errorf("invalid due date %q", s)
+	}
+	ms := due.UnixMilli()
+	return &ms, nil
+}
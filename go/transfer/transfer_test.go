@@ -0,0 +1,78 @@
+package transfer
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juhun32/patriot25-gochi/go/models"
+)
+
+func TestRoundTrip(t *testing.T) {
+	due := time.Date(2026, 3, 8, 7, 30, 15, 250e6, time.UTC).UnixMilli()
+	records := []Record{
+		{Text: "pay rent", Done: false, DueAt: &due, Priority: models.PriorityHigh, Tags: []string{"home", "money"}, Notes: "by transfer", EstimateMinutes: 15},
+		{Text: "water plants", Done: true, Priority: models.PriorityMedium, EstimateMinutes: 90},
+		{Text: "plain"},
+
+		// Text that todo.txt would otherwise read as markers.
+		{Text: "x marks the spot", Priority: models.PriorityLow},
+		{Text: "x", Done: true},
+		{Text: "(A) is not a priority here"},
+		{Text: "2026-01-01 is a date, not a creation date"},
+		{Text: "2026-01-01 2026-01-02 two dates"},
+		{Text: "email +team and @home", Tags: []string{"work"}},
+		{Text: "due:tomorrow pri:A are words"},
+		{Text: `a \backslash and \ alone`},
+		{Text: "+"},
+		{Text: "spaced   out\tand\ttabbed"},
+	}
+
+	for _, format := range []string{FormatJSON, FormatCSV, FormatTodoTxt} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			enc, err := NewEncoder(&buf, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, rec := range records {
+				if err := enc.Encode(rec); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := enc.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []Record
+			err = Decode(&buf, format, time.UTC, func(line int, rec Record, err error) error {
+				if err != nil {
+					t.Errorf("line %d: %v", line, err)
+				}
+				got = append(got, rec)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(records) {
+				t.Fatalf("decoded %d records, want %d\n%s", len(got), len(records), buf.String())
+			}
+
+			for i, want := range records {
+				if format == FormatTodoTxt {
+					// todo.txt has nowhere to put notes or estimates, and separates
+					// words with single spaces, so runs of whitespace in the text,
+					// tabs and line breaks included, come back as one space.
+					want.Notes, want.EstimateMinutes = "", 0
+					want.Text = strings.Join(strings.Fields(want.Text), " ")
+				}
+				if !reflect.DeepEqual(got[i], want) {
+					t.Errorf("record %d\n got  %+v\n want %+v", i, got[i], want)
+				}
+			}
+		})
+	}
+}
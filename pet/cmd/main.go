@@ -1,4 +1,46 @@
 package main
 
+import (
+	"flag"
+	"fmt"
+	"log"
+
+	"github.com/juhun32/patriot25-gochi/pet/internal/storage"
+	"github.com/juhun32/patriot25-gochi/pet/sim"
+)
+
+// Usage: pet [-state state.json] [status|feed|treat|sleep]
 func main() {
+	statePath := flag.String("state", "state.json", "where the pet is kept")
+	flag.Parse()
+	command := flag.Arg(0)
+	if command == "" {
+		command = "status"
+	}
+
+	state, err := storage.LoadState(*statePath)
+	if err != nil {
+		log.Fatal("failed to load pet: ", err)
+	}
+
+	now := sim.SystemClock.Now()
+	p := &state.Pet
+	if !p.Hatched() {
+		p.Stats = sim.New(now)
+	}
+	if command == "status" {
+		p.Stats = p.At(now)
+	} else {
+		if p.Stats, err = p.Apply(sim.Action(command), now); err != nil {
+			log.Fatalf("%s: %v", command, err)
+		}
+		p.TotalInteractions++
+	}
+	p.Mood = p.Stats.Mood()
+
+	if err := storage.SaveState(*statePath, state); err != nil {
+		log.Fatal("failed to save pet: ", err)
+	}
+	fmt.Printf("mood %s · hunger %d · energy %d · affection %d\n",
+		p.Mood, sim.Round(p.Hunger), sim.Round(p.Energy), sim.Round(p.Affection))
 }
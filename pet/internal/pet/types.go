@@ -1,12 +1,14 @@
 package pet
 
-type Mood string
+import "github.com/juhun32/patriot25-gochi/pet/sim"
+
+type Mood = sim.Mood
 type Personality string
 
 const (
-	MoodGrumpy  Mood = "sad"
-	MoodNeutral Mood = "neutral"
-	MoodGolden  Mood = "golden"
+	MoodGrumpy  = sim.MoodGrumpy
+	MoodNeutral = sim.MoodNeutral
+	MoodGolden  = sim.MoodGolden
 )
 
 const (
@@ -16,6 +18,7 @@ const (
 )
 
 type PetState struct {
+	sim.Stats
 	Mood              Mood        `json:"mood"`
 	Personality       Personality `json:"personality"`
 	CompletionRate    float64     `json:"completionRate"`
@@ -0,0 +1,46 @@
+package sim
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells a front end what time it is, so the pet can be simulated at any instant.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// SystemClock is the wall clock.
+var SystemClock Clock = systemClock{}
+
+// TestClock is a Clock that only moves when told to.
+type TestClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewTestClock(now time.Time) *TestClock {
+	return &TestClock{now: now}
+}
+
+func (c *TestClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *TestClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = now
+}
+
+func (c *TestClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,139 @@
+// Package sim is the pet simulation shared by the server, the desktop app and the CLI:
+// the pet's stats, how they wear off over time, the care actions and the mood they add
+// up to. Everything is a pure function of the stats and an instant, so every front end
+// shows the same pet for the same inputs.
+package sim
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// Stats run from MinStat to MaxStat.
+const (
+	MinStat = 0
+	MaxStat = 100
+)
+
+// Stats wear off at these rates per minute.
+const (
+	hungerDecayPerMinute    = 0.5
+	energyDecayPerMinute    = 0.4
+	affectionDecayPerMinute = 0.2
+)
+
+// What the care actions add, and what a treat costs in hunger.
+const (
+	feedBoost          = 30
+	treatBoost         = 20
+	treatHungerCost    = 5
+	sleepBoost         = 40
+	affectionSideBoost = 5
+)
+
+// A new pet starts with these stats.
+const (
+	startHunger    = 80
+	startEnergy    = 75
+	startAffection = 70
+)
+
+type Mood string
+
+const (
+	MoodGrumpy  Mood = "sad"
+	MoodNeutral Mood = "neutral"
+	MoodGolden  Mood = "golden"
+)
+
+type Action string
+
+const (
+	ActionFeed  Action = "feed"
+	ActionTreat Action = "treat"
+	ActionSleep Action = "sleep"
+)
+
+// ErrUnknownAction is returned by Apply for an action other than the ones above.
+var ErrUnknownAction = errors.New("unknown pet action")
+
+// Stats are the pet's needs as of UpdatedAt. They are kept unrounded so that bringing
+// them up to date often gives the same result as doing it once; use Round to show
+// them.
+type Stats struct {
+	Hunger    float64 `json:"hunger" dynamodbav:"hunger"` // 100 is full
+	Energy    float64 `json:"energy" dynamodbav:"energy"`
+	Affection float64 `json:"affection" dynamodbav:"affection"`
+	UpdatedAt int64   `json:"updatedAt" dynamodbav:"updatedAt"` // unix ms
+}
+
+// New returns the stats of a pet born at now.
+func New(now time.Time) Stats {
+	return Stats{
+		Hunger:    startHunger,
+		Energy:    startEnergy,
+		Affection: startAffection,
+		UpdatedAt: now.UnixMilli(),
+	}
+}
+
+// Hatched reports whether s belongs to a pet that exists yet.
+func (s Stats) Hatched() bool {
+	return s.UpdatedAt != 0
+}
+
+// At returns the stats as they will have worn off by now. An instant before UpdatedAt
+// leaves them as they are, so clocks that disagree slightly never make a pet recover.
+func (s Stats) At(now time.Time) Stats {
+	ms := now.UnixMilli()
+	if ms <= s.UpdatedAt {
+		return s
+	}
+	minutes := float64(ms-s.UpdatedAt) / float64(time.Minute/time.Millisecond)
+	s.Hunger = clamp(s.Hunger - minutes*hungerDecayPerMinute)
+	s.Energy = clamp(s.Energy - minutes*energyDecayPerMinute)
+	s.Affection = clamp(s.Affection - minutes*affectionDecayPerMinute)
+	s.UpdatedAt = ms
+	return s
+}
+
+// Apply brings the stats up to now and then carries out action.
+func (s Stats) Apply(action Action, now time.Time) (Stats, error) {
+	s = s.At(now)
+	switch action {
+	case ActionFeed:
+		s.Hunger = clamp(s.Hunger + feedBoost)
+		s.Affection = clamp(s.Affection + affectionSideBoost)
+	case ActionTreat:
+		s.Affection = clamp(s.Affection + treatBoost)
+		s.Hunger = clamp(s.Hunger - treatHungerCost)
+	case ActionSleep:
+		s.Energy = clamp(s.Energy + sleepBoost)
+	default:
+		return s, ErrUnknownAction
+	}
+	return s, nil
+}
+
+// Mood is how the pet feels with these stats: grumpy when hungry or tired, golden
+// when well fed, rested and loved, and neutral otherwise.
+func (s Stats) Mood() Mood {
+	switch {
+	case s.Hunger < 30 || s.Energy < 25:
+		return MoodGrumpy
+	case s.Affection > 75 && s.Energy > 60 && s.Hunger > 60:
+		return MoodGolden
+	default:
+		return MoodNeutral
+	}
+}
+
+// Round is how a stat is shown.
+func Round(v float64) int {
+	return int(math.Round(v))
+}
+
+func clamp(v float64) float64 {
+	return min(max(v, MinStat), MaxStat)
+}
@@ -7,21 +7,13 @@ import (
 	"os"
 	"sync"
 	"time"
-)
 
-const (
-	petStateFile         = "pet_state.json"
-	hungerDecayPerMinute = 0.5 // Changed from per hour
-	energyDecayPerMinute = 0.4 // Changed from per hour
-	affectionPerMinute   = 0.2 // Changed from per hour
-	maxStatValue         = 100
-	minStatValue         = 0
-	feedBoost            = 30
-	treatBoost           = 20
-	sleepBoost           = 40
-	affectionSideBoost   = 5
+	"github.com/juhun32/patriot25-gochi/pet/sim"
 )
 
+const petStateFile = "pet_state.json"
+
+// PetState is the pet as the UI shows it.
 type PetState struct {
 	Hunger      int       `json:"hunger"`
 	Energy      int       `json:"energy"`
@@ -29,23 +21,12 @@ type PetState struct {
 	LastUpdated time.Time `json:"lastUpdated"`
 }
 
-func defaultPetState() PetState {
+func petStateView(stats sim.Stats) PetState {
 	return PetState{
-		Hunger:      80,
-		Energy:      75,
-		Affection:   70,
-		LastUpdated: time.Now(),
-	}
-}
-
-func (ps PetState) mood() string {
-	switch {
-	case ps.Hunger < 30 || ps.Energy < 25:
-		return "sad"
-	case ps.Affection > 75 && ps.Energy > 60 && ps.Hunger > 60:
-		return "golden"
-	default:
-		return "neutral"
+		Hunger:      sim.Round(stats.Hunger),
+		Energy:      sim.Round(stats.Energy),
+		Affection:   sim.Round(stats.Affection),
+		LastUpdated: time.UnixMilli(stats.UpdatedAt),
 	}
 }
 
@@ -54,14 +35,16 @@ type App struct {
 	mu        sync.Mutex
 	Tasks     []string
 	Completed int
-	petState  PetState
+	pet       sim.Stats
+	clock     sim.Clock
 }
 
 func NewApp() *App {
 	return &App{
 		Tasks:     []string{},
 		Completed: 0,
-		petState:  defaultPetState(),
+		pet:       sim.New(sim.SystemClock.Now()),
+		clock:     sim.SystemClock,
 	}
 }
 
@@ -69,7 +52,7 @@ func (a *App) Startup(ctx context.Context) {
 	a.ctx = ctx
 	a.Tasks = []string{}
 	a.Completed = 0
-	a.petState = a.loadPetState()
+	a.pet = a.loadPetState()
 	a.syncPetStateLocked()
 	a.savePetStateLocked()
 	go a.backgroundDecay()
@@ -99,66 +82,66 @@ func (a *App) GetTasks() []string {
 }
 
 func (a *App) Mood() string {
-	state := a.GetPetState()
-	return state.mood()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.syncPetStateLocked()
+	return string(a.pet.Mood())
 }
 
 func (a *App) GetPetState() PetState {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 	a.syncPetStateLocked()
-	return a.petState
+	return petStateView(a.pet)
 }
 
 func (a *App) FeedPet() PetState {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	a.syncPetStateLocked()
-	a.petState.Hunger = clamp(a.petState.Hunger + feedBoost)
-	a.petState.Affection = clamp(a.petState.Affection + affectionSideBoost)
-	a.petState.LastUpdated = time.Now()
-	a.savePetStateLocked()
-	return a.petState
+	return a.petAction(sim.ActionFeed)
 }
 
 func (a *App) GiveTreat() PetState {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-	a.syncPetStateLocked()
-	a.petState.Affection = clamp(a.petState.Affection + treatBoost)
-	a.petState.Hunger = clamp(a.petState.Hunger - 5)
-	a.petState.LastUpdated = time.Now()
-	a.savePetStateLocked()
-	return a.petState
+	return a.petAction(sim.ActionTreat)
 }
 
 func (a *App) PutPetToSleep() PetState {
+	return a.petAction(sim.ActionSleep)
+}
+
+func (a *App) petAction(action sim.Action) PetState {
 	a.mu.Lock()
 	defer a.mu.Unlock()
-	a.syncPetStateLocked()
-	a.petState.Energy = clamp(a.petState.Energy + sleepBoost)
-	a.petState.LastUpdated = time.Now()
-	a.savePetStateLocked()
-	return a.petState
+	if pet, err := a.pet.Apply(action, a.clock.Now()); err == nil {
+		a.pet = pet
+		a.savePetStateLocked()
+	}
+	return petStateView(a.pet)
 }
 
-func (a *App) loadPetState() PetState {
+// loadPetState reads the saved pet. Files written before the shared simulation kept
+// the time as lastUpdated rather than updatedAt.
+func (a *App) loadPetState() sim.Stats {
 	data, err := os.ReadFile(petStateFile)
 	if err != nil {
-		return defaultPetState()
+		return sim.New(a.clock.Now())
+	}
+	var saved struct {
+		sim.Stats
+		LastUpdated time.Time `json:"lastUpdated"`
 	}
-	var state PetState
-	if err := json.Unmarshal(data, &state); err != nil {
-		return defaultPetState()
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return sim.New(a.clock.Now())
 	}
-	if state.LastUpdated.IsZero() {
-		state.LastUpdated = time.Now()
+	if !saved.Hatched() {
+		saved.UpdatedAt = a.clock.Now().UnixMilli()
+		if !saved.LastUpdated.IsZero() {
+			saved.UpdatedAt = saved.LastUpdated.UnixMilli()
+		}
 	}
-	return state
+	return saved.Stats
 }
 
 func (a *App) savePetStateLocked() {
-	payload, err := json.MarshalIndent(a.petState, "", "  ")
+	payload, err := json.MarshalIndent(a.pet, "", "  ")
 	if err != nil {
 		fmt.Println("failed to serialize pet state:", err)
 		return
@@ -169,16 +152,7 @@ func (a *App) savePetStateLocked() {
 }
 
 func (a *App) syncPetStateLocked() {
-	now := time.Now()
-	elapsed := now.Sub(a.petState.LastUpdated)
-	if elapsed <= 0 {
-		return
-	}
-	minutes := elapsed.Minutes()
-	a.petState.Hunger = clamp(a.petState.Hunger - int(minutes*hungerDecayPerMinute))
-	a.petState.Energy = clamp(a.petState.Energy - int(minutes*energyDecayPerMinute))
-	a.petState.Affection = clamp(a.petState.Affection - int(minutes*affectionPerMinute))
-	a.petState.LastUpdated = now
+	a.pet = a.pet.At(a.clock.Now())
 	a.savePetStateLocked()
 }
 
@@ -196,13 +170,3 @@ func (a *App) backgroundDecay() {
 		}
 	}
 }
-
-func clamp(value int) int {
-	if value < minStatValue {
-		return minStatValue
-	}
-	if value > maxStatValue {
-		return maxStatValue
-	}
-	return value
-}
@@ -1,8 +1,11 @@
 module GochiDesktopPet
 
-go 1.23
+go 1.24.3
 
-require github.com/wailsapp/wails/v2 v2.11.0
+require (
+	github.com/juhun32/patriot25-gochi/pet v0.0.0
+	github.com/wailsapp/wails/v2 v2.11.0
+)
 
 require (
 	github.com/bep/debounce v1.2.1 // indirect
@@ -35,3 +38,5 @@ require (
 )
 
 // replace github.com/wailsapp/wails/v2 v2.11.0 => /Users/jeffreygonzalez/go/pkg/mod
+
+replace github.com/juhun32/patriot25-gochi/pet => ../pet